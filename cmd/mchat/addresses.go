@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"mchat/internal/data"
+)
+
+const addressesUsage = `usage:
+  mchat addresses list             list the addresses of the account
+  mchat addresses add ADDRESS      add an alias, @example.com adds the whole domain
+  mchat addresses remove ADDRESS   remove an alias`
+
+// runAddresses manages the own addresses of the account from the command
+// line, the stored messages are corrected after a change
+func runAddresses(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", addressesUsage)
+	}
+	svc, err := data.NewCommandService()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, a := range svc.Addresses() {
+			fmt.Println(a)
+		}
+		return nil
+	case args[0] == "add" && len(args) == 2:
+		err = svc.AddAddress(args[1])
+	case args[0] == "remove" && len(args) == 2:
+		err = svc.RemoveAddress(args[1])
+	default:
+		return fmt.Errorf("%s", addressesUsage)
+	}
+	if err != nil {
+		return err
+	}
+	n, err := svc.FixDirections()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d stored messages corrected\n", n)
+	return nil
+}
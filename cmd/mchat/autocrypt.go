@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"mchat/internal/data"
+	"mchat/internal/pgp"
+	"os"
+	"strings"
+)
+
+const autocryptUsage = `usage:
+  mchat autocrypt key             create your OpenPGP key and announce it in your messages
+  mchat autocrypt mutual on|off   encrypt automatically with peers preferring it too,
+                                  creating your key when you have none
+  mchat autocrypt setup           send the secret key to yourself in a Setup Message
+  mchat autocrypt import FILE     restore the key from the saved Setup Message attachment`
+
+// runAutocrypt manages the Autocrypt account settings from the command line
+func runAutocrypt(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", autocryptUsage)
+	}
+	svc, err := data.NewCommandService()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "key" && len(args) == 1:
+		e, err := svc.CreateAccountKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(pgp.Describe(e))
+		return nil
+	case args[0] == "mutual" && len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+		return svc.SetAutocryptMutual(args[1] == "on")
+	case args[0] == "setup" && len(args) == 1:
+		if err := svc.UnlockKeys(readPassphrase); err != nil {
+			return err
+		}
+		code, err := svc.SendSetupMessage()
+		if err != nil {
+			return err
+		}
+		fmt.Println("The Setup Message was sent to your address, keep this Setup Code to import it:")
+		fmt.Println(code)
+		return nil
+	case args[0] == "import" && len(args) == 2:
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprint(os.Stderr, "Setup Code: ")
+		code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		entities, err := svc.ImportSetupFile(f, strings.TrimSpace(code))
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			fmt.Println("imported", pgp.Describe(e))
+		}
+		return nil
+	}
+	return fmt.Errorf("%s", autocryptUsage)
+}
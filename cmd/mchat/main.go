@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"mchat/internal/data"
 	"mchat/internal/models"
@@ -10,7 +11,24 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// commands are the subcommands run instead of the chat interface
+var commands = map[string]func(args []string) error{
+	"pgp":       runPGP,
+	"autocrypt": runAutocrypt,
+	"addresses": runAddresses,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	logFile, err := setupLogger("mchat.log")
 	if err != nil {
 		log.Fatalf("failed to setup logger: %v", err)
@@ -18,7 +36,7 @@ func main() {
 	defer logFile.Close()
 
 	msgChan := make(chan *models.Message, 100)
-	svc, err := data.NewDataService(msgChan)
+	svc, err := data.NewDataService(msgChan, sessionPassphrase)
 
 	if err != nil {
 		log.Fatalf("failed to setup dataservice: %v", err)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mchat/internal/pgp"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/term"
+)
+
+const pgpUsage = `usage:
+  mchat pgp import [FILE]            import armored or binary keys, stdin by default
+  mchat pgp export [-secret] QUERY   print the keys of an email address or fingerprint,
+                                     secret keys are protected with a passphrase
+  mchat pgp list                     list the keys of the keyring`
+
+// runPGP manages the OpenPGP keyring from the command line
+func runPGP(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", pgpUsage)
+	}
+	keyring, err := pgp.Open()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "import":
+		in := io.Reader(os.Stdin)
+		if len(args) > 1 {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		entities, err := keyring.Import(in, readPassphrase)
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			fmt.Println("imported", pgp.Describe(e))
+		}
+		return nil
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ContinueOnError)
+		secret := fs.Bool("secret", false, "export the secret keys")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%s", pgpUsage)
+		}
+		return keyring.Export(os.Stdout, fs.Arg(0), *secret, newPassphrase)
+	case "list":
+		for _, e := range keyring.Entities() {
+			fmt.Println(pgp.Describe(e))
+		}
+		return nil
+	}
+	return fmt.Errorf("%s", pgpUsage)
+}
+
+// readPassphrase asks for the passphrase of a locked secret key on the
+// terminal
+func readPassphrase(e *openpgp.Entity) ([]byte, error) {
+	return promptPassphrase(fmt.Sprintf("Passphrase for %s: ", pgp.Describe(e)))
+}
+
+// sessionPassphrase asks for the passphrase unlocking a secret key for the
+// session, it may be left locked
+func sessionPassphrase(e *openpgp.Entity) ([]byte, error) {
+	return promptPassphrase(fmt.Sprintf("Passphrase for %s (empty to leave it locked): ", pgp.Describe(e)))
+}
+
+// newPassphrase asks twice for the passphrase protecting an exported
+// secret key
+func newPassphrase(e *openpgp.Entity) ([]byte, error) {
+	pass, err := promptPassphrase(fmt.Sprintf("New passphrase for %s: ", pgp.Describe(e)))
+	if err != nil {
+		return nil, err
+	}
+	again, err := promptPassphrase("Repeat the passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pass, again) {
+		return nil, errors.New("the passphrases don't match")
+	}
+	return pass, nil
+}
+
+func promptPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
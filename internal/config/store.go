@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"mchat/pkg/mailaddr"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -14,10 +17,35 @@ type Config struct {
 	User     string       `json:"user"`
 	Password string       `json:"password,omitempty"`
 	Token    oauth2.Token `json:"token,omitempty"`
+	// SendDelay is the undo-send grace period in seconds, 0 disables it
+	SendDelay int `json:"send_delay"`
+	// AutocryptMutual announces that the user prefers encrypted messages,
+	// chats with peers preferring it too are encrypted automatically
+	AutocryptMutual bool `json:"autocrypt_mutual"`
+	// SMIMETrustStore is a PEM file or a directory of PEM files with the
+	// roots S/MIME signatures are verified against, the system ones if empty
+	SMIMETrustStore string `json:"smime_trust_store,omitempty"`
+	// SMIMEIdentity is a PKCS #12 file signing the outgoing messages
+	SMIMEIdentity         string `json:"smime_identity,omitempty"`
+	SMIMEIdentityPassword string `json:"smime_identity_password,omitempty"`
+	// AuthServId is the authserv-id of the receiving server, only its
+	// Authentication-Results are trusted; the topmost one when empty
+	AuthServId string `json:"authserv_id,omitempty"`
+	// Addresses are the other addresses and aliases of the account, the
+	// messages from them are the user's; "@example.com" stands for every
+	// address of the domain
+	Addresses []string `json:"addresses,omitempty"`
+	// AddressRules fold the spellings of an address into one chat
+	AddressRules mailaddr.Rules `json:"address_rules"`
 }
 
+const (
+	defaultSendDelay = 5
+	maxSendDelay     = 30
+)
+
 func GetDefault() *Config {
-	return &Config{}
+	return &Config{SendDelay: defaultSendDelay, AddressRules: mailaddr.DefaultRules}
 }
 
 func GetPath() (string, error) {
@@ -45,7 +73,7 @@ func LoadConfig() (*Config, error) {
 		}
 		return nil, err
 	}
-	cfg := &Config{}
+	cfg := GetDefault()
 	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
@@ -69,3 +97,35 @@ func (c *Config) SaveConfig() error {
 func (c *Config) IsGoogle() bool {
 	return c.Token.AccessToken != ""
 }
+
+func (c *Config) GetSendDelay() time.Duration {
+	return time.Duration(min(max(c.SendDelay, 0), maxSendDelay)) * time.Second
+}
+
+// NormalizeAddress returns the canonical form of address chats are keyed by
+func (c *Config) NormalizeAddress(address string) string {
+	return c.AddressRules.Normalize(address)
+}
+
+// IsOwnAddress tells whether address is the user's, the account one or one
+// of Addresses
+func (c *Config) IsOwnAddress(address string) bool {
+	if address == "" {
+		return false
+	}
+	address = c.NormalizeAddress(address)
+	if address == c.NormalizeAddress(c.User) {
+		return true
+	}
+	_, domain, _ := strings.Cut(address, "@")
+	for _, a := range c.Addresses {
+		if d, ok := strings.CutPrefix(a, "@"); ok {
+			if domain == mailaddr.Domain(d) {
+				return true
+			}
+		} else if address == c.NormalizeAddress(a) {
+			return true
+		}
+	}
+	return false
+}
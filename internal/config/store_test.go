@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestIsOwnAddress(t *testing.T) {
+	c := GetDefault()
+	c.User = "me@example.com"
+	c.Addresses = []string{"Me@Work.example", "@Me.example"}
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"me@example.com", true},
+		{"ME@EXAMPLE.COM", true},
+		{"me@work.example", true},
+		{"anything@me.example", true},
+		{"anything@sub.me.example", false},
+		{"anna@example.com", false},
+		{"me+news@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := c.IsOwnAddress(tt.address); got != tt.want {
+			t.Errorf("IsOwnAddress(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestIsOwnAddressFoldPlus(t *testing.T) {
+	c := &Config{User: "me@example.com"}
+	c.AddressRules.FoldPlus = true
+	if !c.IsOwnAddress("me+news@example.com") {
+		t.Error("a sub-address of the user is theirs")
+	}
+	if c.IsOwnAddress("ME@example.com") {
+		t.Error("the case of the local part is kept without FoldLocalCase")
+	}
+}
@@ -0,0 +1,168 @@
+package data
+
+import (
+	"fmt"
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"time"
+)
+
+// resolveAlias returns the address of the chat the normalized address was
+// merged into, or address itself
+func (s *DataService) resolveAlias(address string) string {
+	s.aliasesMu.RLock()
+	defer s.aliasesMu.RUnlock()
+	if into, ok := s.aliases[address]; ok {
+		return into
+	}
+	return address
+}
+
+// NormalizeAddress returns the canonical form of address chats are keyed by
+func (s *DataService) NormalizeAddress(address string) string {
+	return s.cfg.NormalizeAddress(address)
+}
+
+// loadAliases reads the merged addresses, normalized with the current rules
+func (s *DataService) loadAliases() error {
+	aliases, err := storage.GetAliases(s.db)
+	if err != nil {
+		return err
+	}
+	s.aliases = make(map[string]string, len(aliases))
+	for alias, into := range aliases {
+		s.aliases[s.cfg.NormalizeAddress(alias)] = s.cfg.NormalizeAddress(into)
+	}
+	return nil
+}
+
+// MergeChat merges the chat of alias into the one of into, the messages of
+// both addresses of the person then go to the latter. It returns the address
+// of the chat merged into, that of into itself when it was merged before.
+func (s *DataService) MergeChat(alias, into string) (string, error) {
+	for _, a := range []string{alias, into} {
+		if models.IsGroup(a) || models.IsChannel(a) {
+			return "", fmt.Errorf("only the chats with one person can be merged, not %s", a)
+		}
+	}
+	alias, into = s.cfg.NormalizeAddress(alias), s.resolveAlias(s.cfg.NormalizeAddress(into))
+	if alias == into {
+		return "", fmt.Errorf("%s is already the address of the chat", alias)
+	}
+	if err := storage.MergeChat(s.db, alias, into); err != nil {
+		return "", err
+	}
+
+	s.aliasesMu.Lock()
+	defer s.aliasesMu.Unlock()
+	if s.aliases == nil {
+		s.aliases = make(map[string]string)
+	}
+	for a, chat := range s.aliases {
+		if chat == alias {
+			s.aliases[a] = into
+		}
+	}
+	s.aliases[alias] = into
+	return into, nil
+}
+
+// normalizeChats renames the stored chats whose address isn't normalized
+// with the current rules, the spellings of an address merge into one chat.
+// It returns the number of chats renamed.
+func (s *DataService) normalizeChats(msgs []*models.Message) (int, error) {
+	renamed := make(map[string]string)
+	for _, m := range msgs {
+		if models.IsChannel(m.ChatAddress) {
+			continue
+		}
+		to, ok := renamed[m.ChatAddress]
+		if !ok {
+			to = s.chatKey(models.Participants(m.ChatAddress))
+			renamed[m.ChatAddress] = to
+			if to != m.ChatAddress {
+				if err := storage.RenameChat(s.db, m.ChatAddress, to); err != nil {
+					return 0, err
+				}
+			}
+		}
+		m.ChatAddress = to
+	}
+
+	n := 0
+	for from, to := range renamed {
+		if from != to {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// seeAddresses records the addresses of the participants of a message as
+// written: the senders of an incoming message at its date, the recipients
+// with none, as they only serve those never seen sending
+func (s *DataService) seeAddresses(m *models.Message) error {
+	if models.IsChannel(m.ChatAddress) {
+		return nil
+	}
+	if !m.Outgoing {
+		senders := m.ReplyTo
+		if len(senders) == 0 {
+			senders = []string{m.From}
+		}
+		for _, a := range senders {
+			if err := storage.SaveSeenAddress(s.db, a, m.Date); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range m.Recipients {
+		if s.cfg.IsOwnAddress(r) {
+			continue
+		}
+		if err := storage.SaveSeenAddress(s.db, r, time.Time{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seeStoredAddresses records the addresses of the stored messages when none
+// are, as in the databases from before they were kept
+func (s *DataService) seeStoredAddresses(msgs []*models.Message) error {
+	seen, err := storage.GetSeenAddresses(s.db)
+	if err != nil || len(seen) > 0 {
+		return err
+	}
+	for _, m := range msgs {
+		if err := s.seeAddresses(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliveryAddresses returns the addresses the participants of a chat are
+// written to: the one each was last seen sending from, the normalized
+// address a chat is keyed by may not reach them
+func (s *DataService) deliveryAddresses(participants []string) ([]string, error) {
+	seen, err := storage.GetSeenAddresses(s.db)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, len(participants))
+	for i, p := range participants {
+		addresses[i] = p
+		var latest time.Time
+		found := false
+		for a, date := range seen {
+			if s.chatKey([]string{a}) != p {
+				continue
+			}
+			if !found || date.After(latest) || date.Equal(latest) && a < addresses[i] {
+				addresses[i], latest, found = a, date, true
+			}
+		}
+	}
+	return addresses, nil
+}
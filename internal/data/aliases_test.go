@@ -0,0 +1,146 @@
+package data
+
+import (
+	"database/sql"
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := storage.OpenDB(filepath.Join(t.TempDir(), "mchat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestNormalizeChats(t *testing.T) {
+	db := testDB(t)
+	for _, m := range []*models.Message{
+		{Id: "1", From: "Bob@Example.com", ChatAddress: "Bob@Example.com"},
+		{Id: "2", From: "bob@example.com", ChatAddress: "bob@example.com"},
+		{Id: "3", From: "bob+work@example.com", ChatAddress: "bob+work@example.com"},
+		{Id: "4", From: "anna@example.com", ChatAddress: "anna@example.com,Bob@Example.com"},
+		{Id: "5", From: "anna@example.com", ChatAddress: "list:go.example.org"},
+	} {
+		if _, err := storage.SaveMessage(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.SaveChatSettings(db, "Bob@Example.com", &models.ChatSettings{Encrypt: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig("me@example.com")
+	cfg.AddressRules.FoldPlus = true
+	s := &DataService{db: db, cfg: cfg}
+	msgs, err := storage.GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.normalizeChats(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("%d chats renamed, want 3", n)
+	}
+
+	msgs, err = storage.GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"1": "bob@example.com", "2": "bob@example.com", "3": "bob@example.com",
+		"4": "anna@example.com,bob@example.com", "5": "list:go.example.org",
+	}
+	for _, m := range msgs {
+		if m.ChatAddress != want[m.Id] {
+			t.Errorf("message %s in chat %q, want %q", m.Id, m.ChatAddress, want[m.Id])
+		}
+	}
+	settings, err := storage.GetChatSetting(db, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.Encrypt {
+		t.Error("the settings of the chat were lost")
+	}
+}
+
+func TestMergeChat(t *testing.T) {
+	db := testDB(t)
+	for _, m := range []*models.Message{
+		{Id: "1", From: "anna@work.example", ChatAddress: "anna@work.example"},
+		{Id: "2", From: "anna@example.com", ChatAddress: "anna@example.com"},
+	} {
+		if _, err := storage.SaveMessage(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &DataService{db: db, cfg: testConfig("me@example.com")}
+	if into, err := s.MergeChat("Anna@Work.example", "anna@example.com"); err != nil || into != "anna@example.com" {
+		t.Fatalf("merged into %q, %v", into, err)
+	}
+	if _, err := s.MergeChat("anna@example.com", "anna@example.com"); err == nil {
+		t.Error("a chat can't be merged into itself")
+	}
+	// a chat merged into an alias goes to the chat the alias was merged into
+	if into, err := s.MergeChat("a@example.org", "anna@work.example"); err != nil || into != "anna@example.com" {
+		t.Errorf("merged into %q, %v", into, err)
+	}
+
+	msgs, err := storage.GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range msgs {
+		if m.ChatAddress != "anna@example.com" {
+			t.Errorf("message %s in chat %q", m.Id, m.ChatAddress)
+		}
+	}
+	if got := s.resolveAlias("anna@work.example"); got != "anna@example.com" {
+		t.Errorf("alias resolved to %q", got)
+	}
+	if err := s.loadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.resolveAlias("anna@work.example"); got != "anna@example.com" {
+		t.Errorf("stored alias resolved to %q", got)
+	}
+}
+
+func TestChatRecipients(t *testing.T) {
+	db := testDB(t)
+	cfg := testConfig("me@example.com")
+	cfg.AddressRules.FoldPlus = true
+	s := &DataService{db: db, cfg: cfg}
+
+	for _, m := range []*models.Message{
+		{From: "Bob+Work@Example.com", Recipients: []string{"me@example.com", "carl+news@example.org"}, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{From: "bob+home@example.com", Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		// an older message fetched later
+		{From: "bob@example.com", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{From: "noreply@tickets.example", ReplyTo: []string{"support@tickets.example"}, Date: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{From: "me@example.com", Recipients: []string{"dan+x@example.net"}, Outgoing: true},
+	} {
+		if err := s.seeAddresses(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.chatRecipients("bob@example.com,carl@example.org,dan@example.net,eve@example.com,support@tickets.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"bob+home@example.com", "carl+news@example.org", "dan+x@example.net", "eve@example.com", "support@tickets.example"}
+	if !slices.Equal(got, want) {
+		t.Errorf("recipients %v, want %v", got, want)
+	}
+}
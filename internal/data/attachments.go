@@ -0,0 +1,193 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"mchat/internal/models"
+	"mchat/pkg/compose"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// MaxMessageSize is the size of an encoded message accepted by Gmail, the
+// attached files count in base64
+const MaxMessageSize = 25 * 1000 * 1000
+
+const (
+	// partOverhead bounds the headers and boundary of an attachment part
+	partOverhead = 1024
+	// messageOverhead leaves room for the headers and the text of the message
+	messageOverhead = 64 * 1024
+)
+
+// encodedSize returns the size of an attachment part of a file of size
+// bytes, in base64 lines of 76 characters
+func encodedSize(size int64) int64 {
+	n := 4 * ((size + 2) / 3)
+	return n + 2*(n/76) + partOverhead
+}
+
+// PrepareAttachment checks the file at path and describes it as an attachment
+// of a message already carrying the queued ones
+func (s *DataService) PrepareAttachment(path string, queued []*models.Attachment) (*models.Attachment, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, rest)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	total := messageOverhead + encodedSize(info.Size())
+	for _, a := range queued {
+		total += encodedSize(a.Size)
+	}
+	if total > MaxMessageSize {
+		return nil, fmt.Errorf("attachments exceed %s once encoded", humanize.Bytes(MaxMessageSize))
+	}
+
+	contentType, err := detectContentType(path)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Attachment{
+		Name:        filepath.Base(path),
+		ContentType: contentType,
+		Size:        info.Size(),
+		Path:        path,
+	}, nil
+}
+
+func detectContentType(path string) (string, error) {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		mediaType, _, err := mime.ParseMediaType(t)
+		if err == nil {
+			return mediaType, nil
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, nil
+}
+
+// attachmentParts reads the attached files into MIME parts,
+// it also returns the files content
+func attachmentParts(attachments []*models.Attachment) ([]*compose.Part, [][]byte, error) {
+	parts := make([]*compose.Part, 0, len(attachments))
+	data := make([][]byte, 0, len(attachments))
+	for _, a := range attachments {
+		content, err := os.ReadFile(a.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, compose.AttachmentPart(a.Name, a.ContentType, content))
+		data = append(data, content)
+	}
+	return parts, data, nil
+}
+
+// SaveAttachment copies the attachment into dir and returns the new file path,
+// an existing file is never overwritten
+func (s *DataService) SaveAttachment(a *models.Attachment, dir string) (string, error) {
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, rest)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	name := safeFilename(a.Name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// OpenAttachment opens a copy of the attachment with the default application
+func (s *DataService) OpenAttachment(a *models.Attachment) error {
+	dir := filepath.Join(os.TempDir(), "mchat", a.Hash)
+	path := filepath.Join(dir, safeFilename(a.Name))
+	if _, err := os.Stat(path); err != nil {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(a.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	cmd := exec.Command(opener, path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Println("error while opening the attachment", err)
+		}
+	}()
+	return nil
+}
+
+// safeFilename strips any directories from a name chosen by the sender
+func safeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return "attachment"
+	}
+	return name
+}
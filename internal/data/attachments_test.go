@@ -0,0 +1,36 @@
+package data
+
+import (
+	"mchat/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareAttachmentEncodedSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := &DataService{}
+	tests := []struct {
+		queued int64
+		ok     bool
+	}{
+		{0, true},
+		{18_000_000, true},
+		// under the limit as a file, over it in base64
+		{18_500_000, false},
+		{24_000_000, false},
+	}
+	for _, tt := range tests {
+		queued := []*models.Attachment{{Name: "video.mp4", Size: tt.queued}}
+		a, err := s.PrepareAttachment(path, queued)
+		if (err == nil) != tt.ok {
+			t.Errorf("with %d bytes queued: got %v", tt.queued, err)
+		}
+		if err == nil && (a.Name != "notes.txt" || a.Size != 5) {
+			t.Errorf("attachment = %+v", a)
+		}
+	}
+}
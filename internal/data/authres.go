@@ -0,0 +1,65 @@
+package data
+
+import (
+	"log"
+	"mchat/internal/models"
+	"mchat/pkg/authres"
+	"net/mail"
+	"strings"
+)
+
+const (
+	authResultsHeader    = "Authentication-Results"
+	arcAuthResultsHeader = "Arc-Authentication-Results"
+)
+
+// authResults returns the checks of the sender made by the receiving server.
+// Anyone can add the headers on the way, so only those of authServId are
+// read, or the topmost one, added last, when it is empty. An ARC header is
+// used when the server added no Authentication-Results.
+func authResults(h mail.Header, authServId string) *authres.Results {
+	authServId = strings.ToLower(authServId)
+	for _, value := range h[authResultsHeader] {
+		r, err := authres.Parse(value)
+		if err != nil {
+			log.Println("ignoring Authentication-Results header", err)
+			continue
+		}
+		if authServId == "" || r.AuthServId == authServId {
+			return r
+		}
+	}
+
+	var latest *authres.Results
+	for _, value := range h[arcAuthResultsHeader] {
+		r, err := authres.ParseARC(value)
+		if err != nil {
+			log.Println("ignoring ARC-Authentication-Results header", err)
+			continue
+		}
+		if authServId != "" && r.AuthServId != authServId {
+			continue
+		}
+		if latest == nil || r.Instance > latest.Instance {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// authVerdict tells whether the sender address of a received message is
+// genuine, and lists the checks it is based on
+func authVerdict(h mail.Header, authServId, from string) (models.AuthStatus, string) {
+	r := authResults(h, authServId)
+	if r == nil {
+		return models.AuthNone, ""
+	}
+	domain := from[strings.LastIndex(from, "@")+1:]
+	switch r.Verdict(domain) {
+	case authres.Pass:
+		return models.AuthPass, r.Summary()
+	case authres.Fail:
+		return models.AuthFail, r.Summary()
+	}
+	return models.AuthNone, r.Summary()
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"mchat/internal/models"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestAuthVerdict(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    string
+		authServId string
+		want       models.AuthStatus
+		results    string
+	}{
+		{
+			name:    "none",
+			headers: "Subject: hi\r\n",
+			want:    models.AuthNone,
+		},
+		{
+			name: "topmost header wins over one added by the sender",
+			headers: "Authentication-Results: mx.example.net; dkim=fail header.d=example.com; dmarc=fail header.from=example.com\r\n" +
+				"Authentication-Results: mx.example.net; dkim=pass header.d=example.com; dmarc=pass header.from=example.com\r\n",
+			want:    models.AuthFail,
+			results: "dkim=fail dmarc=fail",
+		},
+		{
+			name: "only the configured server is trusted",
+			headers: "Authentication-Results: forged.example; dmarc=pass header.from=example.com\r\n" +
+				"Authentication-Results: MX.example.net; spf=pass smtp.mailfrom=example.com; dmarc=pass header.from=example.com\r\n",
+			authServId: "mx.example.net",
+			want:       models.AuthPass,
+			results:    "spf=pass dmarc=pass",
+		},
+		{
+			name:       "no header of the configured server",
+			headers:    "Authentication-Results: forged.example; dmarc=pass header.from=example.com\r\n",
+			authServId: "mx.example.net",
+			want:       models.AuthNone,
+		},
+		{
+			name: "latest ARC instance",
+			headers: "ARC-Authentication-Results: i=1; lists.example.org; dkim=pass header.d=example.com\r\n" +
+				"ARC-Authentication-Results: i=2; mx.example.net; dkim=fail header.d=example.com\r\n",
+			want:    models.AuthFail,
+			results: "dkim=fail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(tt.headers + "\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, results := authVerdict(msg.Header, tt.authServId, "anna@example.com")
+			if got != tt.want || results != tt.results {
+				t.Errorf("got %v %q, want %v %q", got, results, tt.want, tt.results)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mchat/internal/models"
+	"mchat/internal/pgp"
+	"mchat/internal/storage"
+	"mchat/pkg/autocrypt"
+	"mchat/pkg/compose"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const autocryptHeader = "Autocrypt"
+
+// accountKey returns the key of the user, or nil when they have none. Its
+// secret key may be locked.
+func (s *DataService) accountKey() *openpgp.Entity {
+	if e := s.keyring.SecretKey(s.cfg.User); e != nil {
+		return e
+	}
+	return s.keyring.LockedKey(s.cfg.User)
+}
+
+// CreateAccountKey generates the key of the user unless they have one, it
+// is announced in the messages sent from then on
+func (s *DataService) CreateAccountKey() (*openpgp.Entity, error) {
+	if s.cfg.User == "" {
+		return nil, errors.New("the account is not configured")
+	}
+	if e := s.accountKey(); e != nil {
+		return e, nil
+	}
+	log.Println("generating an OpenPGP key for", s.cfg.User)
+	return s.keyring.Generate("", s.cfg.User)
+}
+
+func (s *DataService) preferEncrypt() string {
+	if s.cfg.AutocryptMutual {
+		return autocrypt.PreferMutual
+	}
+	return autocrypt.PreferNoPreference
+}
+
+// autocryptHeader returns the Autocrypt header announcing the user's key,
+// or "" when they have none
+func (s *DataService) autocryptHeader() (string, error) {
+	e := s.accountKey()
+	if e == nil {
+		return "", nil
+	}
+	data, err := pgp.PublicKeyData(e)
+	if err != nil {
+		return "", err
+	}
+	h := &autocrypt.Header{Addr: s.cfg.User, PreferEncrypt: s.preferEncrypt(), KeyData: data}
+	return h.String(), nil
+}
+
+// updateAutocrypt records the Autocrypt header of a received message in the
+// state of its sender
+func (s *DataService) updateAutocrypt(msg *mail.Message, m *models.Message) {
+	if m.Outgoing || msg.Header.Get(autocrypt.SetupHeader) != "" {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(msg.Header.Get("Content-Type")); mediaType == "multipart/report" {
+		// bounces carry the headers of the message that bounced
+		return
+	}
+
+	var header *autocrypt.Header
+	for _, value := range msg.Header[autocryptHeader] {
+		h, err := autocrypt.ParseHeader(value)
+		if err != nil {
+			log.Println("ignoring Autocrypt header", err)
+			continue
+		}
+		if !strings.EqualFold(h.Addr, m.From) {
+			continue
+		}
+		if _, err := pgp.ReadKey(h.KeyData); err != nil {
+			log.Println("ignoring Autocrypt key", err)
+			continue
+		}
+		if header != nil {
+			// several valid headers are treated as none
+			header = nil
+			break
+		}
+		header = h
+	}
+
+	address := strings.ToLower(m.From)
+	peer, err := storage.GetAutocryptPeer(s.db, address)
+	if err != nil {
+		log.Println("error while loading the Autocrypt peer", err)
+		return
+	}
+	if peer == nil {
+		if header == nil {
+			return
+		}
+		peer = &autocrypt.Peer{Addr: address}
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if peer.Update(date, header) {
+		if err := storage.SaveAutocryptPeer(s.db, peer); err != nil {
+			log.Println("error while saving the Autocrypt peer", err)
+		}
+	}
+}
+
+// autocryptKey returns the key announced by the peer, or nil
+func (s *DataService) autocryptKey(address string) *openpgp.Entity {
+	peer, err := storage.GetAutocryptPeer(s.db, strings.ToLower(address))
+	if err != nil {
+		log.Println("error while loading the Autocrypt peer", err)
+		return nil
+	}
+	if peer == nil || len(peer.KeyData) == 0 {
+		return nil
+	}
+	e, err := pgp.ReadKey(peer.KeyData)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return e
+}
+
+// autocryptRecommendation tells whether Autocrypt recommends encrypting a
+// message to the recipients, last is the message it replies to. The
+// recommendation for several is the weakest of them.
+func (s *DataService) autocryptRecommendation(recipients []string, last *models.Message) autocrypt.Recommendation {
+	reply := last != nil && last.Encrypted
+	r := autocrypt.Encrypt
+	for _, address := range recipients {
+		peer, err := storage.GetAutocryptPeer(s.db, strings.ToLower(address))
+		if err != nil {
+			log.Println("error while loading the Autocrypt peer", err)
+			return autocrypt.Disable
+		}
+		r = min(r, peer.Recommend(s.cfg.AutocryptMutual, reply))
+	}
+	return r
+}
+
+// SetAutocryptMutual sets the encryption preference announced to the peers,
+// opting in creates the key of the user
+func (s *DataService) SetAutocryptMutual(mutual bool) error {
+	if mutual {
+		if _, err := s.CreateAccountKey(); err != nil {
+			return err
+		}
+	}
+	s.cfg.AutocryptMutual = mutual
+	return s.cfg.SaveConfig()
+}
+
+// SendSetupMessage sends the secret key of the user to themselves in an
+// Autocrypt Setup Message, the returned Setup Code decrypts it
+func (s *DataService) SendSetupMessage() (string, error) {
+	if s.cfg.User == "" {
+		return "", errors.New("the account is not configured")
+	}
+	e := s.accountKey()
+	if e == nil {
+		return "", errors.New("you have no OpenPGP key, create one with mchat autocrypt key")
+	}
+	if e.PrivateKey.Encrypted {
+		return "", fmt.Errorf("the secret key %s is locked", pgp.Fingerprint(e))
+	}
+	code, err := autocrypt.NewSetupCode()
+	if err != nil {
+		return "", err
+	}
+	file, err := autocrypt.SetupFile(e, s.preferEncrypt(), code)
+	if err != nil {
+		return "", err
+	}
+
+	self := &mail.Address{Address: s.cfg.User}
+	msg := &compose.Message{
+		From:    self,
+		To:      []*mail.Address{self},
+		Subject: autocrypt.SetupSubject,
+		Body: compose.MultipartPart("mixed",
+			compose.TextPart(autocrypt.SetupDescription),
+			compose.AttachmentPart(autocrypt.SetupFilename, autocrypt.SetupContentType, file),
+		),
+	}
+	msg.Header.Add(autocrypt.SetupHeader, autocrypt.SetupVersion)
+	b, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return code, s.sendMail([]string{s.cfg.User}, b)
+}
+
+// ImportSetupFile restores the secret key and the preference of the user
+// from the attachment of an Autocrypt Setup Message
+func (s *DataService) ImportSetupFile(r io.Reader, code string) (openpgp.EntityList, error) {
+	code, err := autocrypt.NormalizeSetupCode(code)
+	if err != nil {
+		return nil, err
+	}
+	entities, preferEncrypt, err := autocrypt.OpenSetupFile(r, code)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		if err := s.keyring.Add(e); err != nil {
+			return nil, err
+		}
+	}
+	return entities, s.SetAutocryptMutual(preferEncrypt == autocrypt.PreferMutual)
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"mchat/internal/config"
+	"mchat/internal/pgp"
+	"mchat/pkg/autocrypt"
+	"testing"
+)
+
+func TestAutocryptHeader(t *testing.T) {
+	keyring := testKeyring(t)
+	s := &DataService{cfg: &config.Config{User: "alice@example.com", AutocryptMutual: true}, keyring: keyring}
+
+	// no key is announced, nor generated, before the user creates one
+	if value, err := s.autocryptHeader(); err != nil || value != "" {
+		t.Fatalf("header %q, %v without a key", value, err)
+	}
+	if len(keyring.Entities()) != 0 {
+		t.Fatal("a key was generated by sending")
+	}
+	if _, err := s.CreateAccountKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := s.autocryptHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := autocrypt.ParseHeader(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Addr != "alice@example.com" || h.PreferEncrypt != autocrypt.PreferMutual {
+		t.Errorf("header = %+v", h)
+	}
+	e, err := pgp.ReadKey(h.KeyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.PrivateKey != nil || pgp.Fingerprint(e) != pgp.Fingerprint(keyring.SecretKey("alice@example.com")) {
+		t.Errorf("announced key = %s", pgp.Describe(e))
+	}
+
+	// the key is generated once
+	if _, err := s.CreateAccountKey(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.autocryptHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != value || len(keyring.Entities()) != 1 {
+		t.Errorf("a new key was generated")
+	}
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"errors"
+	"log"
+	"mchat/internal/models"
+	"mchat/pkg/ical"
+	"strings"
+	"time"
+)
+
+const prodId = "-//mchat//mchat//EN"
+
+// parseEvent returns the first event of an iCalendar object, or nil when
+// there is none
+func parseEvent(ics string) *models.Event {
+	cal, err := ical.Parse(strings.NewReader(ics))
+	if err != nil {
+		log.Println("error while parsing calendar", err)
+		return nil
+	}
+	if len(cal.Events) == 0 {
+		return nil
+	}
+	e := cal.Events[0]
+	event := &models.Event{
+		Method:       cal.Method,
+		UID:          e.UID,
+		Sequence:     e.Sequence,
+		RecurrenceId: e.RecurrenceId,
+		Summary:      e.Summary,
+		Location:     e.Location,
+		Description:  e.Description,
+		Start:        e.Start,
+		End:          e.End,
+		AllDay:       e.AllDay,
+		Cancelled:    cal.Method == ical.MethodCancel || e.Status == "CANCELLED",
+	}
+	if e.Organizer != nil {
+		event.Organizer = &models.Attendee{Name: e.Organizer.Name, Address: e.Organizer.Email}
+	}
+	for _, a := range e.Attendees {
+		event.Attendees = append(event.Attendees, &models.Attendee{Name: a.Name, Address: a.Email, Status: a.PartStat})
+	}
+	return event
+}
+
+// eventCalendar serializes the event as an iCalendar object of its method
+func eventCalendar(e *models.Event) string {
+	event := &ical.Event{
+		UID:          e.UID,
+		Sequence:     e.Sequence,
+		RecurrenceId: e.RecurrenceId,
+		Summary:      e.Summary,
+		Location:     e.Location,
+		Start:        e.Start,
+		End:          e.End,
+		AllDay:       e.AllDay,
+	}
+	if e.Organizer != nil {
+		event.Organizer = &ical.Attendee{Name: e.Organizer.Name, Email: e.Organizer.Address}
+	}
+	for _, a := range e.Attendees {
+		event.Attendees = append(event.Attendees, &ical.Attendee{Name: a.Name, Email: a.Address, PartStat: a.Status})
+	}
+	cal := &ical.Calendar{ProdId: prodId, Method: e.Method, Events: []*ical.Event{event}}
+	return string(cal.Bytes())
+}
+
+// EventReply answers the invitation on behalf of the user with the
+// participation status, e.g. ACCEPTED, as an iTIP REPLY. RFC 5546 sends it
+// to the organizer alone, in the thread of the invitation.
+func (s *DataService) EventReply(invitation *models.Message, status string) (*models.Message, error) {
+	e := invitation.Event
+	if e.Organizer == nil || e.Organizer.Address == "" {
+		return nil, errors.New("the invitation has no organizer to answer")
+	}
+	return &models.Message{
+		To:          e.Organizer.Address,
+		Recipients:  []string{e.Organizer.Address},
+		Contact:     e.Organizer.Name,
+		ChatAddress: invitation.ChatAddress,
+		Subject:     replySubject(invitation.Subject),
+		InReplyTo:   invitation.MessageId,
+		References:  threadReferences(invitation),
+		Event:       s.replyEvent(e, status),
+		Date:        time.Now(),
+		Status:      models.MsgStatusSending,
+		Outgoing:    true,
+	}, nil
+}
+
+// replyEvent is the event of the REPLY to e. The user is the attendee
+// invited with one of their addresses, which is kept as it was written for
+// the organizer to recognize it.
+func (s *DataService) replyEvent(e *models.Event, status string) *models.Event {
+	me := &models.Attendee{Address: s.cfg.User, Status: status}
+	for _, a := range e.Attendees {
+		if s.cfg.IsOwnAddress(a.Address) {
+			me.Name, me.Address = a.Name, a.Address
+			break
+		}
+	}
+	reply := *e
+	reply.Method = ical.MethodReply
+	reply.Description = ""
+	reply.Attendees = []*models.Attendee{me}
+	return &reply
+}
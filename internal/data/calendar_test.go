@@ -0,0 +1,99 @@
+package data
+
+import (
+	"mchat/internal/models"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseBodyInvitation(t *testing.T) {
+	raw, err := os.Open(filepath.Join("testdata", "invite.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	msg, err := mail.ReadMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := parseBody(msg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body.attachments) != 1 || body.attachments[0].Name != "invite.ics" {
+		t.Errorf("attachments = %+v", body.attachments)
+	}
+
+	e := parseEvent(body.calendar)
+	if e == nil {
+		t.Fatal("no event")
+	}
+	if e.Method != "REQUEST" || e.Summary != "Release sync" || e.Cancelled {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Organizer == nil || e.Organizer.Address != "anna@example.com" {
+		t.Errorf("organizer = %+v", e.Organizer)
+	}
+}
+
+func TestEventReply(t *testing.T) {
+	s := &DataService{cfg: testConfig("me@example.org", "Bob@example.org")}
+	invite := &models.Event{
+		Method:    "REQUEST",
+		UID:       "1@example.com",
+		Sequence:  3,
+		Summary:   "Release sync",
+		Organizer: &models.Attendee{Name: "Anna", Address: "anna@example.com"},
+		Attendees: []*models.Attendee{
+			{Name: "Anna", Address: "anna@example.com", Status: "ACCEPTED"},
+			{Name: "Bob", Address: "BOB@Example.org", Status: "NEEDS-ACTION"},
+			{Name: "Carl", Address: "carl@example.net", Status: "NEEDS-ACTION"},
+		},
+	}
+	invitation := &models.Message{
+		ChatAddress: "anna@example.com,carl@example.net",
+		MessageId:   "<invite@example.com>",
+		References:  []string{"<root@example.com>"},
+		Subject:     "Invitation: Release sync",
+		Event:       invite,
+	}
+
+	reply, err := s.EventReply(invitation, "TENTATIVE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.Method != "REQUEST" || len(invite.Attendees) != 3 {
+		t.Errorf("the invitation was modified")
+	}
+	// the organizer alone is answered, in the thread of the invitation
+	if !slices.Equal(reply.Recipients, []string{"anna@example.com"}) || reply.ChatAddress != invitation.ChatAddress {
+		t.Errorf("reply to %q in %q", reply.Recipients, reply.ChatAddress)
+	}
+	if reply.InReplyTo != "<invite@example.com>" || !slices.Equal(reply.References, []string{"<root@example.com>", "<invite@example.com>"}) {
+		t.Errorf("reply threaded to %q %q", reply.InReplyTo, reply.References)
+	}
+	if reply.Subject != "Re: Invitation: Release sync" || !reply.Outgoing {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	got := parseEvent(eventCalendar(reply.Event))
+	if got == nil {
+		t.Fatal("reply not parsed")
+	}
+	if got.Method != "REPLY" || got.UID != invite.UID || got.Sequence != 3 {
+		t.Errorf("reply = %+v", got)
+	}
+	// the attendee invited with an alias keeps the address as written
+	if len(got.Attendees) != 1 || got.Attendees[0].Name != "Bob" || got.Attendees[0].Address != "BOB@Example.org" ||
+		got.Attendees[0].Status != "TENTATIVE" {
+		t.Errorf("attendees = %+v", got.Attendees[0])
+	}
+
+	invite.Organizer = nil
+	if _, err := s.EventReply(invitation, "ACCEPTED"); err == nil {
+		t.Error("an invitation without organizer should not be answered")
+	}
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"errors"
+	"log"
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"mchat/pkg/vcard"
+	"strings"
+)
+
+// parseContact returns the first contact of a vCard, or nil when there is
+// none
+func parseContact(vcf string) *models.Contact {
+	cards, err := vcard.Parse(strings.NewReader(vcf))
+	if err != nil {
+		log.Println("error while parsing vcard", err)
+		return nil
+	}
+	c := cards[0]
+	return &models.Contact{
+		Name:         c.FormattedName,
+		Emails:       c.Emails,
+		Phones:       c.Phones,
+		Organization: c.Organization,
+	}
+}
+
+func (s *DataService) GetContacts() ([]*models.Contact, error) {
+	return storage.GetContacts(s.db)
+}
+
+// contactNames returns the names of the address book by lowercased email
+// address
+func (s *DataService) contactNames() map[string]string {
+	contacts, err := storage.GetContacts(s.db)
+	if err != nil {
+		log.Println("error while loading the contacts", err)
+		return nil
+	}
+	names := make(map[string]string)
+	for _, c := range contacts {
+		for _, e := range c.Emails {
+			names[strings.ToLower(e)] = c.Name
+		}
+	}
+	return names
+}
+
+// SaveContact adds the contact to the address book, a chat can only be
+// started with a contact having an email address
+func (s *DataService) SaveContact(c *models.Contact) error {
+	if len(c.Emails) == 0 {
+		return errors.New("the contact has no email address")
+	}
+	if c.Name == "" {
+		c.Name = c.Emails[0]
+	}
+	return storage.SaveContact(s.db, c)
+}
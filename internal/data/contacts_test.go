@@ -0,0 +1,52 @@
+package data
+
+import (
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBodyContact(t *testing.T) {
+	raw := "From: Anna <anna@example.com>\r\n" +
+		"To: bob@example.org\r\n" +
+		"Subject: Jonas\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Here is the contact of Jonas\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/vcard; charset=utf-8; name=\"Jonas.vcf\"\r\n" +
+		"Content-Disposition: attachment; filename=\"Jonas.vcf\"\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Jonas M=C3=BCller\r\n" +
+		"EMAIL;TYPE=3DINTERNET:jonas@example.de\r\nTEL;TYPE=3DCELL:+49 170 1234567\r\nEND:VCARD\r\n" +
+		"--b--\r\n"
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := parseBody(msg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(body.text) != "Here is the contact of Jonas" {
+		t.Errorf("text = %q", body.text)
+	}
+	if len(body.attachments) != 1 || body.attachments[0].Name != "Jonas.vcf" {
+		t.Errorf("attachments = %+v", body.attachments)
+	}
+
+	c := parseContact(body.vcard)
+	if c == nil {
+		t.Fatal("no contact")
+	}
+	if c.Name != "Jonas Müller" || !reflect.DeepEqual(c.Emails, []string{"jonas@example.de"}) ||
+		!reflect.DeepEqual(c.Phones, []string{"+49 170 1234567"}) {
+		t.Errorf("contact = %+v", c)
+	}
+}
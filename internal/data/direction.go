@@ -0,0 +1,132 @@
+package data
+
+import (
+	"fmt"
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"mchat/pkg/mailaddr"
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// fixDirections corrects the stored messages whose direction changed with
+// the own addresses of the account: the messages from them are outgoing.
+// The chat of the corrected messages is derived again from their sender, or
+// Reply-To, and recipients. The channels keep theirs, as do the messages
+// stored without their Reply-To that were routed to it. It returns the
+// number of messages corrected.
+func (s *DataService) fixDirections(msgs []*models.Message) (int, error) {
+	fixed := 0
+	for _, m := range msgs {
+		outgoing := s.cfg.IsOwnAddress(m.From)
+		if outgoing == m.Outgoing || m.From == "" {
+			continue
+		}
+		if !models.IsChannel(m.ChatAddress) && !s.routedElsewhere(m) {
+			recipients := m.Recipients
+			if len(recipients) == 0 && m.To != "" {
+				// stored before the recipients were
+				recipients = []string{m.To}
+			}
+			to := make([]*mail.Address, len(recipients))
+			for i, r := range recipients {
+				to[i] = &mail.Address{Address: r}
+			}
+			senders := []*mail.Address{{Address: m.From}}
+			if len(m.ReplyTo) > 0 {
+				senders = make([]*mail.Address, len(m.ReplyTo))
+				for i, r := range m.ReplyTo {
+					senders[i] = &mail.Address{Address: r}
+				}
+			}
+			m.ChatAddress = s.chatAddress(senders, to, !outgoing)
+		}
+		m.Outgoing = outgoing
+		if err := storage.UpdateDirection(s.db, m); err != nil {
+			return fixed, err
+		}
+		fixed++
+	}
+	return fixed, nil
+}
+
+// routedElsewhere tells whether an incoming message stored without its
+// Reply-To is in a chat without its sender, as those routed to Reply-To are
+func (s *DataService) routedElsewhere(m *models.Message) bool {
+	if m.Outgoing || len(m.ReplyTo) > 0 {
+		return false
+	}
+	return !slices.Contains(models.Participants(m.ChatAddress), s.chatKey([]string{m.From}))
+}
+
+// FixDirections corrects the direction of the stored messages, to be run
+// after the own addresses changed
+func (s *DataService) FixDirections() (int, error) {
+	msgs, err := storage.GetMessages(s.db)
+	if err != nil {
+		return 0, err
+	}
+	return s.fixDirections(msgs)
+}
+
+// ownAddress returns the form an own address is kept in: the normalized
+// address, or @ and the canonical domain for a whole domain
+func (s *DataService) ownAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if d, ok := strings.CutPrefix(address, "@"); ok {
+		if _, err := mail.ParseAddress("postmaster@" + d); err != nil {
+			return "", fmt.Errorf("invalid domain %q", d)
+		}
+		return "@" + mailaddr.Domain(d), nil
+	}
+	a, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	return s.cfg.NormalizeAddress(a.Address), nil
+}
+
+// hasOwnAddress tells whether the own address, in the form of ownAddress,
+// is the account one or one of config.Addresses
+func (s *DataService) hasOwnAddress(address string) bool {
+	if address == s.cfg.NormalizeAddress(s.cfg.User) {
+		return true
+	}
+	return slices.ContainsFunc(s.cfg.Addresses, func(a string) bool {
+		own, err := s.ownAddress(a)
+		return err == nil && own == address
+	})
+}
+
+// AddAddress adds an own address of the account, see config.Addresses. An
+// address the account already has is left as it is.
+func (s *DataService) AddAddress(address string) error {
+	address, err := s.ownAddress(address)
+	if err != nil {
+		return err
+	}
+	if s.hasOwnAddress(address) {
+		return nil
+	}
+	s.cfg.Addresses = append(s.cfg.Addresses, address)
+	return s.cfg.SaveConfig()
+}
+
+// RemoveAddress removes an own address of the account
+func (s *DataService) RemoveAddress(address string) error {
+	address, err := s.ownAddress(address)
+	if err != nil {
+		return err
+	}
+	s.cfg.Addresses = slices.DeleteFunc(s.cfg.Addresses, func(a string) bool {
+		own, err := s.ownAddress(a)
+		return err == nil && own == address
+	})
+	return s.cfg.SaveConfig()
+}
+
+// Addresses returns the own addresses of the account, the account one first
+func (s *DataService) Addresses() []string {
+	return append([]string{s.cfg.User}, s.cfg.Addresses...)
+}
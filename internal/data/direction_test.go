@@ -0,0 +1,109 @@
+package data
+
+import (
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"slices"
+	"testing"
+)
+
+func TestFixDirections(t *testing.T) {
+	db := testDB(t)
+
+	stored := []*models.Message{
+		// sent from an alias before it was configured
+		{Id: "1", From: "me@work.example", To: "me@example.com", Recipients: []string{"anna@example.com", "me@example.com"}, ChatAddress: "anna@example.com,me@work.example"},
+		// an old message without recipients
+		{Id: "2", From: "me@work.example", To: "bob@example.org", ChatAddress: "me@work.example"},
+		{Id: "3", From: "anna@example.com", To: "me@example.com", ChatAddress: "anna@example.com"},
+		{Id: "4", From: "me@work.example", To: "go@list.example", ChatAddress: "list:go.list.example"},
+	}
+	for _, m := range stored {
+		if _, err := storage.SaveMessage(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &DataService{db: db, cfg: testConfig("me@example.com", "me@work.example")}
+	n, err := s.FixDirections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("%d messages corrected, want 3", n)
+	}
+
+	msgs, err := storage.GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"1": "anna@example.com", "2": "bob@example.org", "3": "anna@example.com", "4": "list:go.list.example"}
+	for _, m := range msgs {
+		if m.ChatAddress != want[m.Id] || m.Outgoing != (m.Id != "3") {
+			t.Errorf("message %s: chat %q outgoing %v", m.Id, m.ChatAddress, m.Outgoing)
+		}
+	}
+}
+
+func TestFixDirectionsReplyTo(t *testing.T) {
+	db := testDB(t)
+
+	stored := []*models.Message{
+		// sent from a shared address the user left, answered at its Reply-To
+		{Id: "1", From: "team@work.example", To: "anna@example.com", Recipients: []string{"anna@example.com"}, ReplyTo: []string{"helpdesk@work.example"}, ChatAddress: "anna@example.com", Outgoing: true},
+		// routed to Reply-To, stored before Reply-To was
+		{Id: "2", From: "me@work.example", To: "me@example.com", ChatAddress: "helpdesk@work.example"},
+	}
+	for _, m := range stored {
+		if _, err := storage.SaveMessage(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &DataService{db: db, cfg: testConfig("me@example.com", "me@work.example")}
+	n, err := s.FixDirections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("%d messages corrected, want 2", n)
+	}
+
+	msgs, err := storage.GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"1": "anna@example.com,helpdesk@work.example", "2": "helpdesk@work.example"}
+	for _, m := range msgs {
+		if m.ChatAddress != want[m.Id] || m.Outgoing != (m.Id == "2") {
+			t.Errorf("message %s: chat %q outgoing %v", m.Id, m.ChatAddress, m.Outgoing)
+		}
+	}
+}
+
+func TestAddAddress(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	s := &DataService{cfg: testConfig("me@example.com")}
+
+	for _, a := range []string{"Me@Work.example", "me@work.example", " @Team.Example", "ME@example.com"} {
+		if err := s.AddAddress(a); err != nil {
+			t.Fatalf("adding %q: %v", a, err)
+		}
+	}
+	for _, a := range []string{"", "not an address", "@", "@bad domain"} {
+		if err := s.AddAddress(a); err == nil {
+			t.Errorf("%q was added", a)
+		}
+	}
+	want := []string{"me@work.example", "@team.example"}
+	if !slices.Equal(s.cfg.Addresses, want) {
+		t.Errorf("addresses %q, want %q", s.cfg.Addresses, want)
+	}
+
+	if err := s.RemoveAddress("ME@work.example"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(s.cfg.Addresses, want[1:]) {
+		t.Errorf("addresses %q after removing one", s.cfg.Addresses)
+	}
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"mchat/internal/config"
+	"mchat/internal/models"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatParticipants(t *testing.T) {
+	s := &DataService{cfg: testConfig("me@example.com")}
+	const user = "me@example.com"
+	tests := []struct {
+		name       string
+		from       string
+		recipients string
+		incoming   bool
+		want       string
+	}{
+		{"incoming", "anna@example.com", "Me <ME@example.com>", true, "anna@example.com"},
+		{"outgoing", user, "anna@example.com", false, "anna@example.com"},
+		{"to self", user, user, true, user},
+		{"incoming group", "anna@example.com", "me@example.com, bob@example.org, Carl <carl@example.net>", true,
+			"anna@example.com,bob@example.org,carl@example.net"},
+		{"reply from another participant", "Bob@example.org", "carl@example.net, anna@example.com, me@example.com", true,
+			"anna@example.com,bob@example.org,carl@example.net"},
+		{"outgoing group", user, "carl@example.net, bob@example.org, anna@example.com, anna@example.com", false,
+			"anna@example.com,bob@example.org,carl@example.net"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := &mail.Address{Address: tt.from}
+			recipients, err := mail.ParseAddressList(tt.recipients)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.chatAddress([]*mail.Address{from}, recipients, tt.incoming)
+			if got != tt.want {
+				t.Errorf("chat key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testConfig returns the default configuration of the account user with
+// the other addresses
+func testConfig(user string, addresses ...string) *config.Config {
+	cfg := config.GetDefault()
+	cfg.User = user
+	cfg.Addresses = addresses
+	return cfg
+}
+
+func TestBuildMessageGroup(t *testing.T) {
+	m := &models.Message{
+		From:        "me@example.com",
+		ChatAddress: "anna@example.com,bob@example.org",
+		Recipients:  []string{"anna@example.com", "bob@example.org"},
+		Contact:     "👥 anna, bob",
+		Content:     "hi all",
+		Date:        time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+	msg, _, err := buildMessage(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(to) != 2 || to[0].Address != "anna@example.com" || to[1].Address != "bob@example.org" || to[0].Name != "" {
+		t.Errorf("To = %v", to)
+	}
+}
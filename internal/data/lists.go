@@ -0,0 +1,123 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"mchat/pkg/compose"
+	"mchat/pkg/mlist"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// unsubscribeTimeout bounds the one-click unsubscription request
+const unsubscribeTimeout = 30 * time.Second
+
+// updateList records the List-* headers of a mailing list message, they
+// tell where replies and unsubscriptions go. A forged message would send
+// them elsewhere, so a known list is only updated by a message whose sender
+// is authenticated, as RFC 8058 asks of one-click unsubscription, and a new
+// one by a message not known to be forged.
+func (s *DataService) updateList(msg *mail.Message, m *models.Message) {
+	list := mlist.Parse(msg.Header)
+	if list == nil || m.Auth == models.AuthFail {
+		return
+	}
+	if m.Auth != models.AuthPass {
+		known, err := storage.GetList(s.db, list.Id)
+		if err != nil {
+			log.Println("error while loading the mailing list", err)
+			return
+		}
+		if known != nil {
+			return
+		}
+	}
+	if err := storage.SaveList(s.db, list); err != nil {
+		log.Println("error while saving the mailing list", err)
+	}
+}
+
+// chatRecipients returns the addresses a message to the chat is sent to:
+// the posting address of a channel, or those the participants were last
+// seen at
+func (s *DataService) chatRecipients(chatAddress string) ([]string, error) {
+	id, ok := models.ChannelId(chatAddress)
+	if !ok {
+		return s.deliveryAddresses(models.Participants(chatAddress))
+	}
+	list, err := storage.GetList(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil || list.Post == "" {
+		return nil, fmt.Errorf("the list %s doesn't accept posts", id)
+	}
+	return []string{list.Post}, nil
+}
+
+// Lists returns the mailing lists of the channels by List-Id
+func (s *DataService) Lists() (map[string]*mlist.List, error) {
+	return storage.GetLists(s.db)
+}
+
+// Unsubscribe leaves the mailing list of a channel: with one click when the
+// list supports it, otherwise by mail. When neither is possible it returns
+// the address of a page to unsubscribe from in a browser.
+func (s *DataService) Unsubscribe(chatAddress string) (string, error) {
+	id, ok := models.ChannelId(chatAddress)
+	if !ok {
+		return "", fmt.Errorf("%s isn't a mailing list", chatAddress)
+	}
+	list, err := storage.GetList(s.db, id)
+	if err != nil {
+		return "", err
+	}
+	if list == nil || len(list.Unsubscribe) == 0 {
+		return "", fmt.Errorf("the list %s gives no way to unsubscribe", id)
+	}
+
+	if url := list.OneClickURL(); url != "" {
+		return "", unsubscribeOneClick(url)
+	}
+	if m := list.UnsubscribeMailto(); m != nil {
+		return "", s.sendUnsubscribe(m)
+	}
+	return list.Unsubscribe[0], nil
+}
+
+// unsubscribeOneClick posts the one-click form of RFC 8058
+func unsubscribeOneClick(url string) error {
+	client := &http.Client{Timeout: unsubscribeTimeout}
+	resp, err := client.Post(url, "application/x-www-form-urlencoded", strings.NewReader(mlist.OneClickBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unsubscribing failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// sendUnsubscribe sends the message of a mailto unsubscription URI
+func (s *DataService) sendUnsubscribe(m *mlist.Mailto) error {
+	subject := m.Subject
+	if subject == "" {
+		subject = "unsubscribe"
+	}
+	msg := &compose.Message{
+		From:    &mail.Address{Address: s.cfg.User},
+		To:      []*mail.Address{{Address: m.Address}},
+		Subject: subject,
+		Body:    compose.TextPart(m.Body),
+	}
+	b, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	return s.sendMail([]string{m.Address}, b)
+}
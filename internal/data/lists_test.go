@@ -0,0 +1,53 @@
+package data
+
+import (
+	"mchat/internal/models"
+	"mchat/internal/storage"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func listMessage(t *testing.T, post string) *mail.Message {
+	t.Helper()
+	raw := "From: anna@example.com\r\n" +
+		"List-Id: Go nuts <golang-nuts.example.org>\r\n" +
+		"List-Post: <mailto:" + post + ">\r\n" +
+		"\r\nhi\r\n"
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestUpdateListAuth(t *testing.T) {
+	s := &DataService{db: testDB(t), cfg: testConfig("me@example.com")}
+	post := func() string {
+		l, err := storage.GetList(s.db, "golang-nuts.example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l == nil {
+			return ""
+		}
+		return l.Post
+	}
+
+	s.updateList(listMessage(t, "evil@attacker.example"), &models.Message{Auth: models.AuthFail})
+	if got := post(); got != "" {
+		t.Errorf("a forged message created the list posting to %q", got)
+	}
+	s.updateList(listMessage(t, "golang-nuts@example.org"), &models.Message{})
+	if got := post(); got != "golang-nuts@example.org" {
+		t.Errorf("post = %q, a new list is recorded unless the message is forged", got)
+	}
+	s.updateList(listMessage(t, "evil@attacker.example"), &models.Message{})
+	if got := post(); got != "golang-nuts@example.org" {
+		t.Errorf("post = %q, an unauthenticated message changed the list", got)
+	}
+	s.updateList(listMessage(t, "nuts@example.org"), &models.Message{Auth: models.AuthPass})
+	if got := post(); got != "nuts@example.org" {
+		t.Errorf("post = %q, an authenticated message updates the list", got)
+	}
+}
@@ -0,0 +1,26 @@
+package data
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown converts chat messages written in Markdown to HTML, raw HTML in
+// the source is not passed through. Line breaks are kept as in a chat.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+func markdownToHTML(source string) (string, error) {
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"></head><body>\n")
+	if err := markdown.Convert([]byte(source), &b); err != nil {
+		return "", err
+	}
+	b.WriteString("</body></html>\n")
+	return b.String(), nil
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"flag"
+	"mchat/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraph", "Hello", "<p>Hello</p>"},
+		{"line breaks are kept", "one\ntwo", "<p>one<br>\ntwo</p>"},
+		{"emphasis", "**big** and *odd*", "<p><strong>big</strong> and <em>odd</em></p>"},
+		{"italic inside a word", "a*b*c", "<p>a<em>b</em>c</p>"},
+		{"snake_case", "snake_case_name", "<p>snake_case_name</p>"},
+		{"code span", "run `go test`", "<p>run <code>go test</code></p>"},
+		{"odd number of backticks", "a ` b", "<p>a ` b</p>"},
+		{"list", "- **done**\n- next", "<ul>\n<li><strong>done</strong></li>\n<li>next</li>\n</ul>"},
+		{"code block", "```go\nx := 1\n```", "<pre><code class=\"language-go\">x := 1\n</code></pre>"},
+		{"raw html is not passed", "<b>hi</b>", "<p><!-- raw HTML omitted -->hi<!-- raw HTML omitted --></p>"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := markdownToHTML(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, ok := strings.CutPrefix(got, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"></head><body>\n")
+			if !ok {
+				t.Fatalf("no document head in %q", got)
+			}
+			body = strings.TrimSpace(strings.TrimSuffix(body, "</body></html>\n"))
+			if body != tt.want {
+				t.Errorf("markdownToHTML(%q)\n got: %q\nwant: %q", tt.in, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildMessageGolden(t *testing.T) {
+	m := &models.Message{
+		From:        "user@example.com",
+		ChatAddress: "friend@example.com",
+		Contact:     "Someone Else", // the address book names the recipient
+		Subject:     "Release",
+		Date:        time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		MessageId:   "<1@example.com>",
+		Content:     "The release is **ready**:\n\n- run `make`\n- tag it\n\n```sh\ngit tag v1.0\n```",
+	}
+	msg, _, err := buildMessage(m, map[string]string{"friend@example.com": "Friend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.Body.Boundary = "mchat-boundary"
+	got, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "markdown.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(expected) {
+		t.Errorf("message mismatch\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -1,98 +1,419 @@
 package data
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"mchat/internal/models"
+	"mchat/internal/pgp"
+	"mchat/internal/smime"
+	"mchat/pkg/flowed"
+	"mchat/pkg/htmltext"
+	"mchat/pkg/mailaddr"
+	"mchat/pkg/mlist"
 	"mime"
 	"mime/multipart"
+	"mime/quotedprintable"
 	"net/mail"
+	"net/textproto"
+	"slices"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
-func getPlainText(msg *mail.Message) (string, error) {
-	contentType := msg.Header.Get("Content-Type")
-	if contentType == "" {
-		body, _ := io.ReadAll(msg.Body)
-		return string(body), nil
+// mailBody holds the parts of a message that mchat makes use of
+type mailBody struct {
+	text string
+	html string
+	// calendar is the first iCalendar object, usually an invitation
+	calendar string
+	// vcard is the first contact card
+	vcard string
+
+	// keys decrypts and verifies the OpenPGP parts and certs the S/MIME
+	// signatures, they may be nil
+	keys         *pgp.Keyring
+	certs        *smime.Verifier
+	encrypted    bool
+	verification models.Verification
+
+	attachments []*models.Attachment
+	// data holds the decoded content of each attachment
+	data [][]byte
+}
+
+func parseBody(msg *mail.Message, keys *pgp.Keyring, certs *smime.Verifier) (*mailBody, error) {
+	b := &mailBody{keys: keys, certs: certs}
+	err := b.parsePart(msg.Body, textproto.MIMEHeader(msg.Header))
+	if strings.TrimSpace(b.text) == "" && b.html != "" {
+		text, htmlErr := htmltext.RenderString(b.html)
+		if htmlErr != nil {
+			log.Println("error while rendering html body", htmlErr)
+		}
+		b.text = text
 	}
-	return parsePart(msg.Body, contentType, msg.Header.Get("Content-Transfer-Encoding"))
+	return b, err
 }
 
-func parsePart(body io.Reader, contentType string, encoding string) (string, error) {
+func (b *mailBody) parsePart(body io.Reader, header textproto.MIMEHeader) error {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
 	mediaType, params, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return "", err
-	}
-
-	if mediaType == "text/plain" {
-		if encoding == "base64" {
-			reader := base64.NewDecoder(base64.StdEncoding, body)
-			content, _ := io.ReadAll(reader)
-			return string(content), nil
-		}
-		content, _ := io.ReadAll(body)
-		return string(content), nil
+		return err
 	}
 
 	if strings.HasPrefix(mediaType, "multipart/") {
+		switch {
+		case mediaType == "multipart/encrypted" && params["protocol"] == "application/pgp-encrypted":
+			return b.parseEncrypted(body, params["boundary"])
+		case mediaType == "multipart/signed" && signatureProtocols[params["protocol"]]:
+			return b.parseSigned(body, params["boundary"], params["protocol"])
+		}
 		mr := multipart.NewReader(body, params["boundary"])
 		for {
 			p, err := mr.NextPart()
 			if err == io.EOF {
-				break
+				return nil
 			}
 			if err != nil {
-				continue
+				return err
 			}
-			result, _ := parsePart(p, p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"))
-			if result != "" {
-				return result, nil
+			if err := b.parsePart(p, p.Header); err != nil {
+				log.Println("error while parsing message part", err)
 			}
 		}
 	}
 
-	return "", nil
+	body = decodeTransfer(body, header.Get("Content-Transfer-Encoding"))
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	name = decodeHeader(name)
+	contentId := strings.Trim(header.Get("Content-ID"), "<> ")
+	inline := disposition != "attachment" && name == ""
+
+	switch {
+	case mediaType == "text/plain" && inline:
+		if strings.TrimSpace(b.text) != "" {
+			return nil
+		}
+		content, err := decodeText(body, params["charset"])
+		if strings.EqualFold(params["format"], "flowed") {
+			content = flowed.Decode(content, strings.EqualFold(params["delsp"], "yes"))
+		}
+		b.text = content
+		return err
+	case mediaType == "text/html" && inline:
+		if b.html != "" {
+			return nil
+		}
+		content, err := decodeHTML(body, contentType)
+		b.html = content
+		return err
+	}
+
+	if isOpaqueSigned(mediaType, params) {
+		return b.parseOpaqueSigned(body)
+	}
+
+	if dst := b.structuredPart(mediaType); dst != nil && *dst == "" {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		*dst = toUTF8(data, params["charset"])
+		if inline {
+			return nil
+		}
+		// a named invite.ics or contact.vcf is also kept as an attachment
+		body = bytes.NewReader(data)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = "attachment"
+		if contentId != "" {
+			name = contentId
+		}
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	b.attachments = append(b.attachments, &models.Attachment{
+		Name:        name,
+		ContentType: mediaType,
+		ContentId:   contentId,
+		Size:        int64(len(data)),
+	})
+	b.data = append(b.data, data)
+	return nil
+}
+
+// structuredPart returns where the first iCalendar or vCard part is kept
+func (b *mailBody) structuredPart(mediaType string) *string {
+	switch mediaType {
+	case "text/calendar", "application/ics":
+		return &b.calendar
+	case "text/vcard", "text/x-vcard", "text/directory":
+		return &b.vcard
+	}
+	return nil
+}
+
+func decodeTransfer(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		// 7bit, 8bit and binary need no decoding
+		return body
+	}
+}
+
+// wordDecoder decodes RFC 2047 encoded words in any charset known to x/text
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	},
+}
+
+// decodeHeader decodes the encoded words of an unstructured header value,
+// raw 8-bit values are converted like undeclared text bodies
+func decodeHeader(value string) string {
+	if !utf8.ValidString(value) {
+		value = toUTF8([]byte(value), "")
+	}
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		log.Printf("error while decoding header %q: %v", value, err)
+		return value
+	}
+	return decoded
+}
+
+// addressList parses an address header decoding the display names
+func addressList(h mail.Header, key string) ([]*mail.Address, error) {
+	value := h.Get(key)
+	if value == "" {
+		return nil, mail.ErrHeaderNotPresent
+	}
+	if !utf8.ValidString(value) {
+		value = toUTF8([]byte(value), "")
+	}
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	return parser.ParseList(value)
+}
+
+// chatParticipants returns the addresses of the chat a message belongs to:
+// the senders and the recipients of an incoming message, the recipients of
+// an outgoing one, leaving out the user's own addresses. The senders are the
+// Reply-To addresses, or the From one.
+func chatParticipants(senders, recipients []*mail.Address, own func(string) bool, incoming bool) []string {
+	var participants []string
+	add := func(a *mail.Address) {
+		if own(a.Address) || slices.ContainsFunc(participants, func(p string) bool {
+			return strings.EqualFold(p, a.Address)
+		}) {
+			return
+		}
+		participants = append(participants, a.Address)
+	}
+	if incoming {
+		for _, a := range senders {
+			add(a)
+		}
+	}
+	for _, r := range recipients {
+		add(r)
+	}
+	if len(participants) == 0 {
+		// a message to themselves
+		if incoming || len(recipients) == 0 {
+			return []string{senders[0].Address}
+		}
+		return []string{recipients[0].Address}
+	}
+	return participants
+}
+
+// alignedReplyTo returns the Reply-To addresses in the domain of the sender,
+// a parent or a subdomain of it. A Reply-To elsewhere would let any sender
+// put a message in the chat of someone else, whose bubbles don't show who
+// sent them.
+func alignedReplyTo(replyTo []*mail.Address, from string) []*mail.Address {
+	fromDomain := addressDomain(from)
+	var aligned []*mail.Address
+	for _, r := range replyTo {
+		d := addressDomain(r.Address)
+		if d == fromDomain || strings.HasSuffix(d, "."+fromDomain) || strings.HasSuffix(fromDomain, "."+d) {
+			aligned = append(aligned, r)
+		}
+	}
+	return aligned
+}
+
+func addressDomain(address string) string {
+	return mailaddr.Domain(address[strings.LastIndex(address, "@")+1:])
+}
+
+// chatAddress returns the address of the chat of a message, with the
+// participants normalized and those merged into another chat replaced
+func (s *DataService) chatAddress(senders, recipients []*mail.Address, incoming bool) string {
+	return s.chatKey(chatParticipants(senders, recipients, s.cfg.IsOwnAddress, incoming))
 }
 
-func (s *DataService) processMessage(msg *mail.Message) *models.Message {
-	fromList, _ := msg.Header.AddressList("From")
-	var from *mail.Address
-	if len(fromList) > 0 {
-		from = fromList[0]
+// chatKey returns the address of the chat with the participants
+func (s *DataService) chatKey(participants []string) string {
+	keys := make([]string, len(participants))
+	for i, p := range participants {
+		keys[i] = s.resolveAlias(s.cfg.NormalizeAddress(p))
 	}
+	return models.ChatKey(keys)
+}
 
-	toList, _ := msg.Header.AddressList("To")
-	if len(toList) == 0 {
-		toList, _ = msg.Header.AddressList("Cc")
+// decodeText converts a text body in the given charset to UTF-8
+func decodeText(body io.Reader, label string) (string, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
 	}
-	if len(toList) == 0 {
-		toList, _ = msg.Header.AddressList("Bcc")
+	return strings.ReplaceAll(toUTF8(content, label), "\r\n", "\n"), nil
+}
+
+func toUTF8(content []byte, label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" || label == "utf-8" || label == "utf8" || label == "us-ascii" {
+		if utf8.Valid(content) {
+			return string(content)
+		}
+		// undeclared 8-bit text is most likely in the Windows western codepage
+		label = "windows-1252"
 	}
-	var to *mail.Address
-	if len(toList) > 0 {
-		to = toList[0]
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		log.Printf("unknown charset %q", label)
+		return strings.ToValidUTF8(string(content), "\uFFFD")
 	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		log.Printf("error while decoding %q text: %v", label, err)
+		return strings.ToValidUTF8(string(content), "\uFFFD")
+	}
+	return string(decoded)
+}
 
-	var chatAddress string
-	if msg.Header.Get("Delivered-To") != "" {
-		chatAddress = from.Address
-	} else {
-		chatAddress = to.Address
+// decodeHTML converts an HTML body to UTF-8, taking the charset from the
+// content type or the document meta tags
+func decodeHTML(body io.Reader, contentType string) (string, error) {
+	r, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return "", err
 	}
+	content, err := io.ReadAll(r)
+	return string(content), err
+}
 
-	content, err := getPlainText(msg)
+// processMessage converts a fetched mail into a chat message, the returned
+// slice holds the content of the message attachments. A mail without a
+// sender belongs to no chat and is refused.
+func (s *DataService) processMessage(msg *mail.Message) (*models.Message, [][]byte, error) {
+	fromList, err := addressList(msg.Header, "From")
 	if err != nil {
-		content = ""
+		return nil, nil, fmt.Errorf("no valid From address: %w", err)
+	}
+	if len(fromList) == 0 {
+		return nil, nil, errors.New("no From address")
+	}
+	from := fromList[0]
+
+	toList, _ := addressList(msg.Header, "To")
+	ccList, _ := addressList(msg.Header, "Cc")
+	recipients := append(toList, ccList...)
+	if len(recipients) == 0 {
+		recipients, _ = addressList(msg.Header, "Bcc")
+	}
+	var to string
+	if len(recipients) > 0 {
+		to = recipients[0].Address
+	}
+	var recipientAddresses []string
+	for _, r := range recipients {
+		recipientAddresses = append(recipientAddresses, r.Address)
+	}
+
+	// the user's messages come back from other clients and the sent folder
+	incoming := !s.cfg.IsOwnAddress(from.Address)
+	// replies go to Reply-To, as for ticketing systems sending from noreply@
+	replyTo, _ := addressList(msg.Header, "Reply-To")
+	replyTo = alignedReplyTo(replyTo, from.Address)
+	var replyToAddresses []string
+	for _, r := range replyTo {
+		replyToAddresses = append(replyToAddresses, r.Address)
+	}
+	senders := replyTo
+	if len(senders) == 0 {
+		senders = []*mail.Address{from}
+	}
+	chatAddress := s.chatAddress(senders, recipients, incoming)
+	if list := mlist.Parse(msg.Header); list != nil {
+		// the messages of a mailing list gather in its channel
+		chatAddress = models.ChannelKey(list.Id)
+	}
+
+	body, err := parseBody(msg, s.keyring, s.certs)
+	if err != nil {
+		log.Println("error while parsing message body", err)
 	}
 	date, err := msg.Header.Date()
 	if err != nil {
 		log.Println(err)
 	}
+	messageId := msg.Header.Get("Message-ID")
 	id := msg.Header.Get(mChatIdHeader)
 	if id == "" {
-		id = msg.Header.Get("Message-ID")
+		id = messageId
+	}
+	subject := decodeHeader(msg.Header.Get("Subject"))
+	var event *models.Event
+	if body.calendar != "" {
+		event = parseEvent(body.calendar)
+	}
+	var card *models.Contact
+	if body.vcard != "" {
+		card = parseContact(body.vcard)
+	}
+	v := body.verification
+	if v.Status == models.SignatureValid && !slices.ContainsFunc(v.Emails, func(e string) bool {
+		return strings.EqualFold(e, from.Address)
+	}) {
+		// a good signature by someone else than the sender
+		v.Status = models.SignatureInvalid
+	}
+	var auth models.AuthStatus
+	var authResults string
+	if incoming {
+		// the user's own messages aren't checked
+		auth, authResults = authVerdict(msg.Header, s.cfg.AuthServId, from.Address)
 	}
 
 	return &models.Message{
@@ -100,35 +421,26 @@ func (s *DataService) processMessage(msg *mail.Message) *models.Message {
 		Contact:     from.Name,
 		ChatAddress: chatAddress,
 		From:        from.Address,
-		To:          to.Address,
-		Content:     removeQuotedText(content),
+		To:          to,
+		Recipients:  recipientAddresses,
+		ReplyTo:     replyToAddresses,
+		Content:     removeQuotedText(body.text),
+		Body:        body.text,
 		Date:        date,
-	}
-}
-
-func removeQuotedText(s string) string {
-	var stopAt int
-	lines := strings.SplitAfter(s, "\n")
-
-	for i, l := range lines {
-		if len(l) > 3 && l[:3] == "___" {
-			// Microsoft
-			stopAt = i
-			break
-		}
-		if l != "" && l[0] == '>' {
-			// Google
-			stopAt = max(0, i-3)
-			break
-		}
-	}
-	if stopAt == 0 {
-		return s
-	}
-
-	res := ""
-	for _, l := range lines[:stopAt] {
-		res += l
-	}
-	return strings.TrimSpace(res)
+		Outgoing:    !incoming,
+		MessageId:   messageId,
+		InReplyTo:   msg.Header.Get("In-Reply-To"),
+		References:  strings.Fields(msg.Header.Get("References")),
+		Subject:     subject,
+		Attachments: body.attachments,
+		Calendar:    body.calendar,
+		Event:       event,
+		VCard:       body.vcard,
+		Card:        card,
+		Encrypted:   body.encrypted,
+		Signature:   v.Status,
+		Signer:      v.Signer,
+		Auth:        auth,
+		AuthResults: authResults,
+	}, body.data, nil
 }
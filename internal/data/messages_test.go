@@ -1,6 +1,10 @@
 package data
 
 import (
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -16,3 +20,62 @@ On 2006-01-02 MChat wrote:
 		t.Errorf("expected %q got %q", expected, result)
 	}
 }
+
+func TestParseBodyCharsets(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "charsets", "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test messages found")
+	}
+
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".eml")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.Open(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer raw.Close()
+			expected, err := os.ReadFile(strings.TrimSuffix(f, ".eml") + ".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := mail.ReadMessage(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := parseBody(msg, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := strings.TrimSpace(body.text)
+			if result != strings.TrimSpace(string(expected)) {
+				t.Errorf("expected %q got %q", strings.TrimSpace(string(expected)), result)
+			}
+		})
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"Plain subject", "Plain subject"},
+		{"=?UTF-8?B?WmHFvMOzxYLEhw==?=", "Zażółć"},
+		{"=?iso-8859-2?Q?Faktura_za_wrzesie=F1?=", "Faktura za wrzesień"},
+		{"=?windows-1252?Q?=93Quoted=94?= text", "“Quoted” text"},
+		{"=?koi8-r?B?8NLJ18XU?=", "Привет"},
+		{"=?ISO-2022-JP?B?GyRCJDMkcyRLJEEkTxsoQg==?=", "こんにちは"},
+		{"Gr\xfc\xdfe", "Grüße"},
+	}
+	for _, tt := range tests {
+		result := decodeHeader(tt.value)
+		if result != tt.expected {
+			t.Errorf("decodeHeader(%q): expected %q got %q", tt.value, tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mchat/internal/models"
+	"mchat/internal/pgp"
+	"mchat/internal/storage"
+	"mchat/pkg/autocrypt"
+	"mchat/pkg/compose"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// encryptBody replaces body by its PGP/MIME encryption to the recipients
+// of the message, signed with the user's key when there is one
+func (s *DataService) encryptBody(body *compose.Part, m *models.Message) (*compose.Part, error) {
+	var b bytes.Buffer
+	if _, err := body.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	to, err := s.recipientKeys(m.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	armored, signed, err := s.keyring.Encrypt(b.Bytes(), to, s.cfg.User)
+	if err != nil {
+		return nil, err
+	}
+	m.Encrypted = true
+	if signed {
+		m.Signature = models.SignatureValid
+		m.Signer = s.cfg.User
+	}
+	return compose.EncryptedPart(armored), nil
+}
+
+// recipientKey returns the key to encrypt to address with: an imported
+// key, or the one announced with Autocrypt
+func (s *DataService) recipientKey(address string) *openpgp.Entity {
+	if e := s.keyring.PublicKey(address); e != nil {
+		return e
+	}
+	return s.autocryptKey(address)
+}
+
+// recipientKeys returns the keys of every recipient
+func (s *DataService) recipientKeys(recipients []string) ([]*openpgp.Entity, error) {
+	var keys []*openpgp.Entity
+	for _, p := range recipients {
+		e := s.recipientKey(p)
+		if e == nil {
+			return nil, fmt.Errorf("no OpenPGP key for %s", p)
+		}
+		keys = append(keys, e)
+	}
+	return keys, nil
+}
+
+// shouldEncrypt tells whether a message to the chat is encrypted: when the
+// chat setting asks for it or Autocrypt recommends it for the recipients,
+// last is the message it replies to
+func (s *DataService) shouldEncrypt(chatAddress string, recipients []string, last *models.Message) (bool, error) {
+	settings, err := storage.GetChatSetting(s.db, chatAddress)
+	if err != nil {
+		return false, err
+	}
+	if settings.Encrypt {
+		return true, nil
+	}
+	return s.autocryptRecommendation(recipients, last) == autocrypt.Encrypt, nil
+}
+
+// UnlockKeys unlocks the protected secret keys of the keyring for the
+// session
+func (s *DataService) UnlockKeys(passphrase pgp.Passphrase) error {
+	if passphrase == nil {
+		return nil
+	}
+	return s.keyring.Unlock(passphrase)
+}
+
+// ChatSettings returns the settings of every chat that has any
+func (s *DataService) ChatSettings() (map[string]*models.ChatSettings, error) {
+	return storage.GetChatSettings(s.db)
+}
+
+// SetEncrypt turns the encryption of a chat on or off, it fails when there
+// is no key to encrypt to
+func (s *DataService) SetEncrypt(chatAddress string, encrypt bool) error {
+	if encrypt {
+		recipients, err := s.chatRecipients(chatAddress)
+		if err != nil {
+			return err
+		}
+		if _, err := s.recipientKeys(recipients); err != nil {
+			return fmt.Errorf("%w, import it with mchat pgp import", err)
+		}
+	}
+	return storage.SaveChatSettings(s.db, chatAddress, &models.ChatSettings{Encrypt: encrypt})
+}
+
+// parseEncrypted decrypts a multipart/encrypted part and parses the
+// decrypted entity
+func (b *mailBody) parseEncrypted(body io.Reader, boundary string) error {
+	var encrypted []byte
+	mr := multipart.NewReader(body, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// the first part only holds the version, the second the message
+		if strings.HasPrefix(p.Header.Get("Content-Type"), "application/octet-stream") {
+			encrypted, err = io.ReadAll(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	b.encrypted = true
+	if encrypted == nil {
+		return errors.New("the encrypted message has no content")
+	}
+	if b.keys == nil {
+		b.text = "[encrypted message, no keyring to decrypt it]"
+		return nil
+	}
+	plain, v, err := b.keys.Decrypt(bytes.NewReader(encrypted))
+	if err != nil {
+		b.text = "[encrypted message that could not be decrypted: " + err.Error() + "]"
+		return err
+	}
+	if v.Status != models.SignatureNone {
+		b.verification = v
+	}
+	return b.parseEntity(plain)
+}
+
+// parseSigned verifies a multipart/signed part, OpenPGP or S/MIME, and
+// parses the signed entity. The signature covers the raw bytes of the
+// first part.
+func (b *mailBody) parseSigned(body io.Reader, boundary, protocol string) error {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	parts := splitMultipart(string(raw), boundary)
+	if len(parts) != 2 {
+		return fmt.Errorf("a signed message has 2 parts, not %d", len(parts))
+	}
+
+	header, signature, err := readEntity([]byte(parts[1]))
+	if err != nil {
+		return err
+	}
+	signature = decodeTransfer(signature, header.Get("Content-Transfer-Encoding"))
+	// the signed data is in canonical form, with CRLF line endings
+	signed := []byte(strings.ReplaceAll(parts[0], "\n", "\r\n"))
+	b.verification = models.Verification{Status: models.SignatureUnknownKey}
+	switch {
+	case protocol == pgpSignature && b.keys != nil:
+		b.verification = b.keys.Verify(signed, signature)
+	case protocol != pgpSignature:
+		der, err := io.ReadAll(signature)
+		if err != nil {
+			return err
+		}
+		b.verification = b.certs.VerifyDetached(signed, der)
+	}
+	return b.parseEntity([]byte(parts[0]))
+}
+
+// parseEntity parses a MIME entity, headers and body
+func (b *mailBody) parseEntity(raw []byte) error {
+	header, body, err := readEntity(raw)
+	if err != nil {
+		return err
+	}
+	return b.parsePart(body, header)
+}
+
+func readEntity(raw []byte) (textproto.MIMEHeader, io.Reader, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw)))
+	header, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return header, r.R, nil
+}
+
+// splitMultipart returns the raw parts of a multipart body with LF line
+// endings, mime/multipart can't be used as it loses the exact content
+func splitMultipart(body, boundary string) []string {
+	delimiter := "--" + boundary
+	var parts []string
+	var lines []string
+	inPart := false
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		switch strings.TrimRight(line, " \t") {
+		case delimiter:
+			if inPart {
+				parts = append(parts, strings.Join(lines, "\n"))
+			}
+			lines, inPart = nil, true
+		case delimiter + "--":
+			if inPart {
+				parts = append(parts, strings.Join(lines, "\n"))
+			}
+			return parts
+		default:
+			if inPart {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return parts
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"bytes"
+	"mchat/internal/config"
+	"mchat/internal/models"
+	"mchat/internal/pgp"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func testKeyring(t *testing.T, entities ...*openpgp.Entity) *pgp.Keyring {
+	t.Helper()
+	k, err := pgp.OpenFile(filepath.Join(t.TempDir(), "keyring.pgp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entities {
+		if err := k.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k
+}
+
+func testEntity(t *testing.T, name, email string) *openpgp.Entity {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	alice := testEntity(t, "Alice", "alice@example.com")
+	bob := testEntity(t, "Bob", "bob@example.org")
+	s := &DataService{cfg: &config.Config{User: "alice@example.com"}, keyring: testKeyring(t, alice, bob)}
+
+	m := &models.Message{
+		From:        "alice@example.com",
+		ChatAddress: "bob@example.org",
+		Content:     "the password is *hunter2*",
+		Subject:     "Secret",
+		Date:        time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		MessageId:   "<1@example.com>",
+	}
+	msg, _, err := buildMessage(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.Body, err = s.encryptBody(msg.Body, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Encrypted || m.Signature != models.SignatureValid {
+		t.Errorf("sent message = %+v", m)
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("hunter2")) {
+		t.Fatal("the content is sent in clear")
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := parseBody(parsed, testKeyring(t, bob, alice), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !body.encrypted || body.verification.Status != models.SignatureValid {
+		t.Errorf("encrypted = %v, verification = %+v", body.encrypted, body.verification)
+	}
+	if strings.TrimSpace(body.text) != "the password is *hunter2*" {
+		t.Errorf("text = %q", body.text)
+	}
+	if !strings.Contains(body.html, "<em>hunter2</em>") {
+		t.Errorf("html = %q", body.html)
+	}
+}
+
+func TestParseBodySigned(t *testing.T) {
+	alice := testEntity(t, "Alice", "alice@example.com")
+	signed := "Content-Type: text/plain; charset=utf-8\n" +
+		"Content-Transfer-Encoding: quoted-printable\n\nSigned hello=\n world\n"
+	var sig bytes.Buffer
+	// the line break before the boundary is not part of the signed entity
+	canonical := strings.ReplaceAll(strings.TrimSuffix(signed, "\n"), "\n", "\r\n")
+	if err := openpgp.ArmoredDetachSign(&sig, alice, strings.NewReader(canonical), nil); err != nil {
+		t.Fatal(err)
+	}
+	raw := "From: alice@example.com\nTo: bob@example.org\n" +
+		"Content-Type: multipart/signed; boundary=b; micalg=pgp-sha256;\n protocol=\"application/pgp-signature\"\n\n" +
+		"--b\n" + signed + "--b\nContent-Type: application/pgp-signature; name=signature.asc\n\n" +
+		sig.String() + "\n--b--\n"
+
+	tests := []struct {
+		name string
+		raw  string
+		keys *pgp.Keyring
+		want models.SignatureStatus
+	}{
+		{"known key", raw, testKeyring(t, alice), models.SignatureValid},
+		{"unknown key", raw, testKeyring(t), models.SignatureUnknownKey},
+		{"tampered", strings.Replace(raw, "Signed hello", "Signed hullo", 1), testKeyring(t, alice), models.SignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := parseBody(msg, tt.keys, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body.verification.Status != tt.want {
+				t.Errorf("verification = %+v, want %v", body.verification, tt.want)
+			}
+			if !strings.HasPrefix(body.text, "S") || !strings.HasSuffix(strings.TrimSpace(body.text), " world") {
+				t.Errorf("text = %q", body.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A replyRule recognises the line where the quoted history or the signature
+// of a mail starts. match returns the index of the first line to strip, which
+// may be before i for attributions wrapped over several lines.
+type replyRule struct {
+	name  string
+	match func(lines []string, i int) (int, bool)
+}
+
+var (
+	// "-- " is the standard signature separator, some clients trim the space
+	signatureRe       = regexp.MustCompile(`^--\s*$`)
+	mobileSignatureRe = regexp.MustCompile(`^(Sent from my |Sent from Mail for |Get Outlook for |Von meinem .+ gesendet|Gesendet von |Wysłane z |Wysłano z |Envoyé de mon )`)
+	// Outlook separates the reply from the original message with a line
+	separatorRe = regexp.MustCompile(`^(_{3,}|-{3,}\s*(Original Message|Ursprüngliche Nachricht|Wiadomość oryginalna|Oryginalna wiadomość|Message d'origine|Mensaje original)\s*-{3,})\s*$`)
+	// Outlook and other desktop clients start the history with a header block
+	headerFromRe = regexp.MustCompile(`^\*?(From|Von|Od|De)\s?:\*?\s`)
+	headerSentRe = regexp.MustCompile(`^\*?(Sent|Date|Gesendet|Datum|Wysłano|Data|Envoyé|Enviado)\s?:\*?\s`)
+	// attributions end the line introducing the quote, in every language mchat knows
+	attributionEndRe = regexp.MustCompile(`((wrote|napisał\(a\)|napisał|napisała|pisze|a écrit|escribió|ha scritto)\s?|\b(schrieb|schreef)\b.*):\s*$`)
+	// attributionStartRe recognises the first line of wrapped attributions
+	attributionStartRe = regexp.MustCompile(`^(On|Am|W dniu|Le|El|Op|Il giorno)\s`)
+	// maxAttributionLines is the longest attribution a client is expected to wrap to
+	maxAttributionLines = 3
+)
+
+var replyRules = []replyRule{
+	{name: "signature", match: func(lines []string, i int) (int, bool) {
+		return i, signatureRe.MatchString(lines[i])
+	}},
+	{name: "mobile signature", match: func(lines []string, i int) (int, bool) {
+		return i, mobileSignatureRe.MatchString(strings.TrimSpace(lines[i]))
+	}},
+	{name: "separator", match: func(lines []string, i int) (int, bool) {
+		return i, separatorRe.MatchString(strings.TrimSpace(lines[i]))
+	}},
+	{name: "header block", match: func(lines []string, i int) (int, bool) {
+		return i, isHeaderBlock(lines, i)
+	}},
+	{name: "attribution", match: func(lines []string, i int) (int, bool) {
+		// a line ending in "wrote:" may as well introduce text pasted in
+		// the reply, the history follows an attribution
+		if isQuote(lines[i]) || !historyFollows(lines, i) {
+			return i, false
+		}
+		single := attributionEndRe.MatchString(strings.TrimSpace(lines[i]))
+		// look back for the start of an attribution wrapped over several lines
+		joined := ""
+		for start := i; start > i-maxAttributionLines && start >= 0; start-- {
+			l := strings.TrimSpace(lines[start])
+			if l == "" {
+				break
+			}
+			joined = strings.TrimSpace(l + " " + joined)
+			if attributionStartRe.MatchString(l) && attributionEndRe.MatchString(joined) {
+				return start, true
+			}
+		}
+		return i, single
+	}},
+	{name: "quote", match: func(lines []string, i int) (int, bool) {
+		// only a quote running to the end is history, interleaved replies are kept
+		for _, l := range lines[i:] {
+			if strings.TrimSpace(l) != "" && !isQuote(l) {
+				return i, false
+			}
+		}
+		return i, isQuote(lines[i])
+	}},
+}
+
+func isQuote(l string) bool {
+	return strings.HasPrefix(strings.TrimLeft(l, " "), ">")
+}
+
+// isHeaderBlock tells whether the header block of a forwarded or quoted
+// message starts at line i
+func isHeaderBlock(lines []string, i int) bool {
+	if !headerFromRe.MatchString(strings.TrimSpace(lines[i])) {
+		return false
+	}
+	for _, l := range lines[i+1 : min(i+5, len(lines))] {
+		if headerSentRe.MatchString(strings.TrimSpace(l)) {
+			return true
+		}
+	}
+	return false
+}
+
+// historyFollows tells whether the next non-empty line after i is a quote
+// or a header block
+func historyFollows(lines []string, i int) bool {
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) != "" {
+			return isQuote(lines[j]) || isHeaderBlock(lines, j)
+		}
+	}
+	return false
+}
+
+// extractReply splits a mail body into the reply written by the sender and
+// the quoted history or signature that follows it. A body without a
+// recognised reply is returned whole.
+func extractReply(body string) (reply, rest string) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i := range lines {
+		for _, rule := range replyRules {
+			start, ok := rule.match(lines, i)
+			if !ok {
+				continue
+			}
+			reply = strings.TrimSpace(strings.Join(lines[:start], "\n"))
+			if reply == "" {
+				return body, ""
+			}
+			return reply, strings.TrimSpace(strings.Join(lines[start:], "\n"))
+		}
+	}
+	return body, ""
+}
+
+func removeQuotedText(s string) string {
+	reply, _ := extractReply(s)
+	return reply
+}
@@ -0,0 +1,221 @@
+package data
+
+import "testing"
+
+func TestExtractReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		reply string
+		rest  string
+	}{
+		{
+			name:  "no history",
+			body:  "Just a message\nwith two lines",
+			reply: "Just a message\nwith two lines",
+		},
+		{
+			name:  "gmail",
+			body:  "Sounds good!\n\nOn Mon, Jan 2, 2006 at 3:04 PM John Doe <john@example.com> wrote:\n\n> Lunch tomorrow?\n",
+			reply: "Sounds good!",
+			rest:  "On Mon, Jan 2, 2006 at 3:04 PM John Doe <john@example.com> wrote:\n\n> Lunch tomorrow?",
+		},
+		{
+			name:  "gmail wrapped attribution",
+			body:  "Sounds good!\n\nOn Mon, Jan 2, 2006 at 3:04 PM John Doe <\njohn@example.com> wrote:\n\n> Lunch tomorrow?",
+			reply: "Sounds good!",
+			rest:  "On Mon, Jan 2, 2006 at 3:04 PM John Doe <\njohn@example.com> wrote:\n\n> Lunch tomorrow?",
+		},
+		{
+			name:  "gmail attribution wrapped over three lines",
+			body:  "Yes\n\nOn Mon, Jan 2, 2006 at 3:04 PM\nJonathan Livingston Seagull\n<jonathan@example.com> wrote:\n> Fly?",
+			reply: "Yes",
+			rest:  "On Mon, Jan 2, 2006 at 3:04 PM\nJonathan Livingston Seagull\n<jonathan@example.com> wrote:\n> Fly?",
+		},
+		{
+			name:  "apple mail",
+			body:  "Sure thing\n\nSent from my iPhone\n\n> On Jan 2, 2006, at 15:04, John Doe <john@example.com> wrote:\n> \n> Lunch tomorrow?",
+			reply: "Sure thing",
+			rest:  "Sent from my iPhone\n\n> On Jan 2, 2006, at 15:04, John Doe <john@example.com> wrote:\n> \n> Lunch tomorrow?",
+		},
+		{
+			name:  "apple mail without signature",
+			body:  "Sure thing\n\n> On Jan 2, 2006, at 15:04, John Doe <john@example.com> wrote:\n> \n> Lunch tomorrow?",
+			reply: "Sure thing",
+			rest:  "> On Jan 2, 2006, at 15:04, John Doe <john@example.com> wrote:\n> \n> Lunch tomorrow?",
+		},
+		{
+			name:  "thunderbird",
+			body:  "Agreed.\n\nOn 02/01/2006 15:04, John Doe wrote:\n> Lunch tomorrow?\n>\n> John",
+			reply: "Agreed.",
+			rest:  "On 02/01/2006 15:04, John Doe wrote:\n> Lunch tomorrow?\n>\n> John",
+		},
+		{
+			name:  "thunderbird german",
+			body:  "Einverstanden.\n\nAm 02.01.06 um 15:04 schrieb John Doe:\n> Mittagessen morgen?",
+			reply: "Einverstanden.",
+			rest:  "Am 02.01.06 um 15:04 schrieb John Doe:\n> Mittagessen morgen?",
+		},
+		{
+			name:  "gmail german",
+			body:  "Gerne!\n\nAm Mo., 2. Jan. 2006 um 15:04 Uhr schrieb John Doe <\njohn@example.com>:\n\n> Mittagessen morgen?",
+			reply: "Gerne!",
+			rest:  "Am Mo., 2. Jan. 2006 um 15:04 Uhr schrieb John Doe <\njohn@example.com>:\n\n> Mittagessen morgen?",
+		},
+		{
+			name:  "gmail polish",
+			body:  "Pasuje!\n\npon., 2 sty 2006 o 15:04 Jan Kowalski <jan@example.com> napisał(a):\n\n> Obiad jutro?",
+			reply: "Pasuje!",
+			rest:  "pon., 2 sty 2006 o 15:04 Jan Kowalski <jan@example.com> napisał(a):\n\n> Obiad jutro?",
+		},
+		{
+			name:  "thunderbird polish",
+			body:  "Pasuje.\n\nW dniu 2.01.2006 o 15:04, Jan Kowalski pisze:\n> Obiad jutro?",
+			reply: "Pasuje.",
+			rest:  "W dniu 2.01.2006 o 15:04, Jan Kowalski pisze:\n> Obiad jutro?",
+		},
+		{
+			name:  "thunderbird polish wrapped",
+			body:  "Pasuje.\n\nW dniu 2.01.2006 o 15:04, Jan Kowalski\n<jan@example.com> pisze:\n> Obiad jutro?",
+			reply: "Pasuje.",
+			rest:  "W dniu 2.01.2006 o 15:04, Jan Kowalski\n<jan@example.com> pisze:\n> Obiad jutro?",
+		},
+		{
+			name:  "french",
+			body:  "D'accord.\n\nLe lun. 2 janv. 2006 à 15:04, John Doe <john@example.com> a écrit :\n> Déjeuner demain ?",
+			reply: "D'accord.",
+			rest:  "Le lun. 2 janv. 2006 à 15:04, John Doe <john@example.com> a écrit :\n> Déjeuner demain ?",
+		},
+		{
+			name:  "attribution followed by a header block",
+			body:  "Forwarding it.\n\nOn Mon, Jan 2, 2006 at 3:04 PM John Doe wrote:\nFrom: Jane <jane@example.com>\nDate: Mon, Jan 2, 2006\n\nLunch?",
+			reply: "Forwarding it.",
+			rest:  "On Mon, Jan 2, 2006 at 3:04 PM John Doe wrote:\nFrom: Jane <jane@example.com>\nDate: Mon, Jan 2, 2006\n\nLunch?",
+		},
+		{
+			name:  "pasted text after wrote is kept",
+			body:  "Here is what the reviewer wrote:\n\nThe patch needs tests.\nPlease add them.",
+			reply: "Here is what the reviewer wrote:\n\nThe patch needs tests.\nPlease add them.",
+		},
+		{
+			name:  "pasted text after schrieb is kept",
+			body:  "Das schrieb der Kunde:\nBitte bis Freitag liefern.",
+			reply: "Das schrieb der Kunde:\nBitte bis Freitag liefern.",
+		},
+		{
+			name:  "attribution at the end is kept",
+			body:  "Lunch tomorrow?\nOn Mon, Jan 2, 2006 at 3:04 PM John Doe wrote:",
+			reply: "Lunch tomorrow?\nOn Mon, Jan 2, 2006 at 3:04 PM John Doe wrote:",
+		},
+		{
+			name:  "outlook separator",
+			body:  "Works for me\r\n\r\n________________________________\r\nFrom: John Doe <john@example.com>\r\nSent: Monday, January 2, 2006 3:04 PM\r\nTo: Me\r\nSubject: Lunch\r\n\r\nLunch tomorrow?",
+			reply: "Works for me",
+			rest:  "________________________________\nFrom: John Doe <john@example.com>\nSent: Monday, January 2, 2006 3:04 PM\nTo: Me\nSubject: Lunch\n\nLunch tomorrow?",
+		},
+		{
+			name:  "outlook header block",
+			body:  "Works for me\n\nFrom: John Doe <john@example.com>\nSent: Monday, January 2, 2006 3:04 PM\nTo: Me\nSubject: Lunch\n\nLunch tomorrow?",
+			reply: "Works for me",
+			rest:  "From: John Doe <john@example.com>\nSent: Monday, January 2, 2006 3:04 PM\nTo: Me\nSubject: Lunch\n\nLunch tomorrow?",
+		},
+		{
+			name:  "outlook bold header block",
+			body:  "Works for me\n\n*From:* John Doe <john@example.com>\n*Sent:* Monday, January 2, 2006 3:04 PM\n*To:* Me\n\nLunch tomorrow?",
+			reply: "Works for me",
+			rest:  "*From:* John Doe <john@example.com>\n*Sent:* Monday, January 2, 2006 3:04 PM\n*To:* Me\n\nLunch tomorrow?",
+		},
+		{
+			name:  "outlook german header block",
+			body:  "Passt\n\nVon: John Doe <john@example.com>\nGesendet: Montag, 2. Januar 2006 15:04\nAn: Ich\nBetreff: Mittag",
+			reply: "Passt",
+			rest:  "Von: John Doe <john@example.com>\nGesendet: Montag, 2. Januar 2006 15:04\nAn: Ich\nBetreff: Mittag",
+		},
+		{
+			name:  "outlook polish header block",
+			body:  "Pasuje\n\nOd: Jan Kowalski <jan@example.com>\nWysłano: poniedziałek, 2 stycznia 2006 15:04\nDo: Ja\nTemat: Obiad",
+			reply: "Pasuje",
+			rest:  "Od: Jan Kowalski <jan@example.com>\nWysłano: poniedziałek, 2 stycznia 2006 15:04\nDo: Ja\nTemat: Obiad",
+		},
+		{
+			name:  "outlook original message",
+			body:  "OK\n\n-----Original Message-----\nFrom: John Doe\nLunch tomorrow?",
+			reply: "OK",
+			rest:  "-----Original Message-----\nFrom: John Doe\nLunch tomorrow?",
+		},
+		{
+			name:  "outlook german original message",
+			body:  "OK\n\n-----Ursprüngliche Nachricht-----\nVon: John Doe",
+			reply: "OK",
+			rest:  "-----Ursprüngliche Nachricht-----\nVon: John Doe",
+		},
+		{
+			name:  "signature",
+			body:  "See you there.\n\n-- \nJohn Doe\nACME Corp.",
+			reply: "See you there.",
+			rest:  "-- \nJohn Doe\nACME Corp.",
+		},
+		{
+			name:  "signature without trailing space",
+			body:  "See you there.\n--\nJohn",
+			reply: "See you there.",
+			rest:  "--\nJohn",
+		},
+		{
+			name:  "signature before history",
+			body:  "See you there.\n\n-- \nJohn\n\nOn Mon, Jan 2, 2006 at 3:04 PM Jane wrote:\n> Lunch?",
+			reply: "See you there.",
+			rest:  "-- \nJohn\n\nOn Mon, Jan 2, 2006 at 3:04 PM Jane wrote:\n> Lunch?",
+		},
+		{
+			name:  "german mobile signature",
+			body:  "Bis gleich\n\nVon meinem iPhone gesendet",
+			reply: "Bis gleich",
+			rest:  "Von meinem iPhone gesendet",
+		},
+		{
+			name:  "polish mobile signature",
+			body:  "Do zobaczenia\n\nWysłane z iPhone'a",
+			reply: "Do zobaczenia",
+			rest:  "Wysłane z iPhone'a",
+		},
+		{
+			name:  "trailing quote without attribution",
+			body:  "Yes\n\n> Lunch?\n> Tomorrow?",
+			reply: "Yes",
+			rest:  "> Lunch?\n> Tomorrow?",
+		},
+		{
+			name:  "interleaved reply is kept",
+			body:  "> Lunch?\nYes\n> Where?\nThe usual place",
+			reply: "> Lunch?\nYes\n> Where?\nThe usual place",
+		},
+		{
+			name:  "quote only is kept",
+			body:  "> Lunch?",
+			reply: "> Lunch?",
+		},
+		{
+			name:  "body line mentioning from is kept",
+			body:  "From: the team\nThanks for all the fish",
+			reply: "From: the team\nThanks for all the fish",
+		},
+		{
+			name:  "markdown rule is not a signature",
+			body:  "Part one\n---\nPart two",
+			reply: "Part one\n---\nPart two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, rest := extractReply(tt.body)
+			if reply != tt.reply {
+				t.Errorf("expected reply %q got %q", tt.reply, reply)
+			}
+			if rest != tt.rest {
+				t.Errorf("expected rest %q got %q", tt.rest, rest)
+			}
+		})
+	}
+}
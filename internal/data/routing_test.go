@@ -0,0 +1,124 @@
+package data
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestProcessMessageRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  string
+		chat     string
+		outgoing bool
+	}{
+		{
+			name: "mailing list",
+			headers: "Delivered-To: me@example.com\r\nFrom: Anna <anna@example.com>\r\nTo: golang-nuts@googlegroups.com\r\n" +
+				"List-Id: <golang-nuts.googlegroups.com>\r\nList-Post: <mailto:golang-nuts@googlegroups.com>\r\n",
+			chat: "list:golang-nuts.googlegroups.com",
+		},
+		{
+			name: "own post to a mailing list",
+			headers: "From: me@example.com\r\nTo: golang-nuts@googlegroups.com\r\n" +
+				"List-Id: Go Nuts <golang-nuts.googlegroups.com>\r\n",
+			chat:     "list:golang-nuts.googlegroups.com",
+			outgoing: true,
+		},
+		{
+			name:    "group",
+			headers: "Delivered-To: me@example.com\r\nFrom: Bob <bob@example.org>\r\nTo: me@example.com\r\nCc: anna@example.com\r\n",
+			chat:    "anna@example.com,bob@example.org",
+		},
+		{
+			name: "reply-to",
+			headers: "Delivered-To: me@example.com\r\nFrom: Shop <noreply@shop.example>\r\n" +
+				"Reply-To: Support <support+4711@shop.example>\r\nTo: me@example.com\r\n",
+			chat: "support+4711@shop.example",
+		},
+		{
+			name: "reply-to in a parent domain",
+			headers: "Delivered-To: me@example.com\r\nFrom: Shop <noreply@mail.shop.example>\r\n" +
+				"Reply-To: support@shop.example\r\nTo: me@example.com\r\n",
+			chat: "support@shop.example",
+		},
+		{
+			// anyone could send to the chat with the boss otherwise
+			name: "reply-to in another domain",
+			headers: "Delivered-To: me@example.com\r\nFrom: Boss <x@evil.example>\r\n" +
+				"Reply-To: boss@corp.example\r\nTo: me@example.com\r\n",
+			chat: "x@evil.example",
+		},
+		{
+			name:    "merged address",
+			headers: "Delivered-To: me@example.com\r\nFrom: Anna <Anna@Work.example>\r\nTo: me@example.com\r\n",
+			chat:    "anna@example.com",
+		},
+		{
+			name:    "group with a merged address",
+			headers: "Delivered-To: me@example.com\r\nFrom: Bob <bob@example.org>\r\nTo: me@example.com, anna@work.example\r\n",
+			chat:    "anna@example.com,bob@example.org",
+		},
+		{
+			name:    "spelling of the address",
+			headers: "From: Anna <ANNA@Example.COM>\r\nTo: me@example.com\r\n",
+			chat:    "anna@example.com",
+		},
+		{
+			name:     "from an alias, through another client",
+			headers:  "From: Me <me@work.example>\r\nTo: anna@example.com, me@example.com\r\n",
+			chat:     "anna@example.com",
+			outgoing: true,
+		},
+		{
+			name:    "incoming without Delivered-To",
+			headers: "From: Anna <anna@example.com>\r\nTo: me@work.example\r\n",
+			chat:    "anna@example.com",
+		},
+		{
+			name:     "to self",
+			headers:  "Delivered-To: me@example.com\r\nFrom: me@example.com\r\nTo: me@example.com\r\n",
+			chat:     "me@example.com",
+			outgoing: true,
+		},
+		{
+			name:     "outgoing",
+			headers:  "From: me@example.com\r\nTo: Anna <anna@example.com>\r\n",
+			chat:     "anna@example.com",
+			outgoing: true,
+		},
+	}
+	s := &DataService{
+		cfg:     testConfig("me@example.com", "me@work.example"),
+		aliases: map[string]string{"anna@work.example": "anna@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(tt.headers + "Date: Mon, 19 Oct 2026 10:00:00 +0000\r\nSubject: hi\r\n\r\nhello\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, _, err := s.processMessage(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.ChatAddress != tt.chat || m.Outgoing != tt.outgoing {
+				t.Errorf("chat %q outgoing %v, want %q %v", m.ChatAddress, m.Outgoing, tt.chat, tt.outgoing)
+			}
+		})
+	}
+}
+
+func TestProcessMessageWithoutSender(t *testing.T) {
+	s := &DataService{cfg: testConfig("me@example.com")}
+	for _, from := range []string{"", "From: undisclosed\r\n", "From: \r\n"} {
+		msg, err := mail.ReadMessage(strings.NewReader(from + "To: me@example.com\r\nSubject: hi\r\n\r\nhello\r\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.processMessage(msg); err == nil {
+			t.Errorf("a message with %q was accepted", from)
+		}
+	}
+}
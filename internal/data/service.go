@@ -1,33 +1,80 @@
 package data
 
 import (
-	"bytes"
 	"database/sql"
 	"fmt"
 	"log"
+	"net/mail"
 	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"mchat/internal/auth_google"
 	"mchat/internal/config"
 	"mchat/internal/models"
+	"mchat/internal/pgp"
+	"mchat/internal/smime"
 	"mchat/internal/storage"
+	"mchat/pkg/compose"
 	"mchat/pkg/oxsmtp"
 	"mchat/pkg/pop3"
 
+	"github.com/dustin/go-humanize"
 	"golang.org/x/oauth2"
 )
 
-const mChatIdHeader = "X-MChat-Id"
+const (
+	mChatIdHeader  = "X-MChat-Id"
+	defaultSubject = "Notification from MChat"
+	// maxReferences limits the References header of long running chats
+	maxReferences = 20
+)
 
 type DataService struct {
-	db              *sql.DB
-	cfg             *config.Config
-	msgChan         chan<- *models.Message
+	db      *sql.DB
+	cfg     *config.Config
+	msgChan chan<- *models.Message
+	keyring *pgp.Keyring
+	certs   *smime.Verifier
+
+	// existingMsgsIds holds the ids of the messages shown, the polling
+	// reads it while the UI sends messages
+	idsMu           sync.Mutex
 	existingMsgsIds map[string]struct{}
+
+	// aliases maps the merged addresses to the chat they belong to, the
+	// polling reads it while the UI merges chats
+	aliasesMu sync.RWMutex
+	aliases   map[string]string
 }
 
-func NewDataService(msgChan chan<- *models.Message) (*DataService, error) {
+// NewDataService loads the stored messages and polls for new ones, the
+// passphrase unlocks the protected secret keys for the session
+func NewDataService(msgChan chan<- *models.Message, passphrase pgp.Passphrase) (*DataService, error) {
+	svc, err := NewCommandService()
+	if err != nil {
+		return nil, err
+	}
+	// the keys are unlocked before the encrypted messages are read
+	if err := svc.UnlockKeys(passphrase); err != nil {
+		return nil, err
+	}
+	svc.msgChan = msgChan
+	err = svc.loadExistingMessages()
+	if err != nil {
+		log.Println(err)
+	}
+
+	go svc.startPolling()
+
+	return svc, nil
+}
+
+// NewCommandService returns a service for the command line, it neither
+// loads the stored messages nor polls for new ones
+func NewCommandService() (*DataService, error) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return nil, err
@@ -38,15 +85,24 @@ func NewDataService(msgChan chan<- *models.Message) (*DataService, error) {
 		return nil, err
 	}
 
-	svc := &DataService{db: db, cfg: cfg, msgChan: msgChan, existingMsgsIds: make(map[string]struct{})}
-	err = svc.loadExistingMessages()
+	keyring, err := pgp.Open()
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
 
-	go svc.startPolling()
+	certs, err := smime.NewVerifier(cfg.SMIMETrustStore)
+	if err != nil {
+		return nil, err
+	}
 
-	return svc, nil
+	s := &DataService{
+		db: db, cfg: cfg, existingMsgsIds: make(map[string]struct{}),
+		keyring: keyring, certs: certs,
+	}
+	if err := s.loadAliases(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (s *DataService) loadExistingMessages() error {
@@ -54,13 +110,44 @@ func (s *DataService) loadExistingMessages() error {
 	if err != nil {
 		return err
 	}
+	if n, err := s.normalizeChats(msgs); err != nil {
+		log.Println("error while normalizing the chat addresses", err)
+	} else if n > 0 {
+		log.Println(n, "chats renamed to their normalized address")
+	}
+	if _, err := s.fixDirections(msgs); err != nil {
+		log.Println("error while fixing the direction of the messages", err)
+	}
+	if err := s.seeStoredAddresses(msgs); err != nil {
+		log.Println("error while recording the addresses of the messages", err)
+	}
 	for _, m := range msgs {
-		s.existingMsgsIds[m.Id] = struct{}{}
+		if m.Calendar != "" {
+			m.Event = parseEvent(m.Calendar)
+		}
+		if m.VCard != "" {
+			m.Card = parseContact(m.VCard)
+		}
+		s.addKnown(m.Id)
 		s.msgChan <- m
 	}
 	return nil
 }
 
+// isKnown tells whether the message with the id is already shown
+func (s *DataService) isKnown(id string) bool {
+	s.idsMu.Lock()
+	defer s.idsMu.Unlock()
+	_, ok := s.existingMsgsIds[id]
+	return ok
+}
+
+func (s *DataService) addKnown(id string) {
+	s.idsMu.Lock()
+	defer s.idsMu.Unlock()
+	s.existingMsgsIds[id] = struct{}{}
+}
+
 func (s *DataService) startPolling() {
 	for {
 		if s.cfg.User != "" {
@@ -78,43 +165,165 @@ func (s *DataService) startPolling() {
 
 func (s *DataService) SendMessage(m *models.Message) error {
 	// Sets From and Id fields - without err - and sends the message
-	m.Id = fmt.Sprintf("<%d@mchat.mchat>", time.Now().UnixNano())
+	m.Id = compose.NewMessageId(s.cfg.User)
 	m.From = s.cfg.User
+	m.Outgoing = true
+	if len(m.Recipients) == 0 {
+		// every participant of a group chat is addressed
+		recipients, err := s.chatRecipients(m.ChatAddress)
+		if err != nil {
+			return err
+		}
+		m.Recipients = recipients
+	}
+	m.To = m.Recipients[0]
 
-	token, err := s.GetActiveToken()
+	m.MessageId = m.Id
+	// a message without subject continues the thread of the chat, answers
+	// to invitations come threaded
+	var last *models.Message
+	if m.Subject == "" {
+		m.Subject = defaultSubject
+		var err error
+		last, err = storage.GetLastThreadMessage(s.db, m.ChatAddress)
+		if err != nil {
+			log.Println("error when looking up the chat thread", err)
+		} else if last != nil {
+			m.Subject = replySubject(last.Subject)
+			m.InReplyTo = last.MessageId
+			m.References = threadReferences(last)
+		}
+	}
+
+	if m.Event != nil {
+		m.Calendar = eventCalendar(m.Event)
+	}
+	msg, data, err := buildMessage(m, s.contactNames())
 	if err != nil {
 		return err
 	}
-	smtpAuth := oxsmtp.Auth{User: s.cfg.User, Token: token}
-
-	var b bytes.Buffer
-	fmt.Fprintf(&b, "From: %s\r\n", m.From)
-	fmt.Fprintf(&b, "To: %s\r\n", m.ChatAddress)
-	fmt.Fprintf(&b, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
-	fmt.Fprintf(&b, "Subject: Notification from MChat\r\n")
-	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
-	fmt.Fprintf(&b, "Content-Type: text/plain; charset=\"utf-8\"\r\n")
-	fmt.Fprintf(&b, "%s: %s\r\n", mChatIdHeader, m.Id)
-	fmt.Fprintf(&b, "\r\n")
-	fmt.Fprint(&b, m.Content)
-
-	err = smtp.SendMail("smtp.gmail.com:587", smtpAuth, s.cfg.User, []string{m.ChatAddress}, b.Bytes())
+	encrypt, err := s.shouldEncrypt(m.ChatAddress, m.Recipients, last)
 	if err != nil {
 		return err
 	}
+	if encrypt {
+		msg.Body, err = s.encryptBody(msg.Body, m)
+	} else if s.cfg.SMIMEIdentity != "" {
+		msg.Body, err = s.signBody(msg.Body, m)
+	}
+	if err != nil {
+		return err
+	}
+	if header, err := s.autocryptHeader(); err != nil {
+		log.Println("error while preparing the Autocrypt header", err)
+	} else if header != "" {
+		msg.Header.Add(autocryptHeader, header)
+	}
+	msg.Header.Add(mChatIdHeader, m.Id)
+	b, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	if len(b) > MaxMessageSize {
+		return fmt.Errorf("the message exceeds %s", humanize.Bytes(MaxMessageSize))
+	}
+	m.Date = msg.Date
 
-	err = storage.SaveMessage(s.db, m)
+	err = s.sendMail(m.Recipients, b)
 	if err != nil {
+		return err
+	}
+
+	// the copy fetched back from the sent folder is already shown
+	s.addKnown(m.Id)
+	if _, err := storage.SaveMessage(s.db, m); err != nil {
 		log.Println("error when saving the message", err)
+		return nil
+	}
+	if err := storage.SaveAttachments(s.db, m, data); err != nil {
+		log.Println("error when saving the attachments", err)
 	}
 	return nil
 }
 
-func (s *DataService) SaveBasicConfig(user, pass string) {
-	s.cfg = &config.Config{
-		User:     user,
-		Password: pass,
+// sendMail sends a message from the user's account
+func (s *DataService) sendMail(to []string, msg []byte) error {
+	token, err := s.GetActiveToken()
+	if err != nil {
+		return err
+	}
+	auth := oxsmtp.Auth{User: s.cfg.User, Token: token}
+	return smtp.SendMail("smtp.gmail.com:587", auth, s.cfg.User, to, msg)
+}
+
+// buildMessage composes the mail for m, naming the recipients found in names
+// by lowercased address. It also returns the content of the attached files.
+func buildMessage(m *models.Message, names map[string]string) (*compose.Message, [][]byte, error) {
+	html, err := markdownToHTML(m.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	recipients := m.Recipients
+	if len(recipients) == 0 {
+		recipients = []string{m.ChatAddress}
+	}
+	var to []*mail.Address
+	for _, r := range recipients {
+		// the name of the chat isn't the recipient's: a channel is named
+		// after the first poster, a chat may start with an own message
+		to = append(to, &mail.Address{Name: names[strings.ToLower(r)], Address: r})
+	}
+	msg := &compose.Message{
+		From:       &mail.Address{Address: m.From},
+		To:         to,
+		Subject:    m.Subject,
+		Date:       m.Date,
+		MessageId:  m.MessageId,
+		InReplyTo:  m.InReplyTo,
+		References: m.References,
+		Body:       compose.MultipartPart("alternative", compose.FlowedTextPart(m.Content), compose.HTMLPart(html)),
+	}
+	if m.Calendar != "" {
+		msg.Body.Parts = append(msg.Body.Parts, compose.CalendarPart([]byte(m.Calendar), m.Event.Method))
 	}
+
+	var data [][]byte
+	if len(m.Attachments) > 0 {
+		var parts []*compose.Part
+		parts, data, err = attachmentParts(m.Attachments)
+		if err != nil {
+			return nil, nil, err
+		}
+		msg.Body = compose.MultipartPart("mixed", append([]*compose.Part{msg.Body}, parts...)...)
+	}
+	return msg, data, nil
+}
+
+func replySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return defaultSubject
+	}
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// threadReferences returns the References of a reply to m, keeping the
+// thread root and the most recent ancestors
+func threadReferences(m *models.Message) []string {
+	refs := append(slices.Clone(m.References), m.MessageId)
+	if len(refs) > maxReferences {
+		refs = append(refs[:1], refs[len(refs)-maxReferences+1:]...)
+	}
+	return refs
+}
+
+func (s *DataService) SaveBasicConfig(user, pass string) {
+	s.cfg.User = user
+	s.cfg.Password = pass
+	s.cfg.Token = oauth2.Token{}
 	err := s.cfg.SaveConfig()
 	if err != nil {
 		log.Println(err)
@@ -122,16 +331,19 @@ func (s *DataService) SaveBasicConfig(user, pass string) {
 }
 
 func (s *DataService) SaveGoogleConfig(user string, token *oauth2.Token) {
-	s.cfg = &config.Config{
-		User:  user,
-		Token: *token,
-	}
+	s.cfg.User = user
+	s.cfg.Password = ""
+	s.cfg.Token = *token
 	err := s.cfg.SaveConfig()
 	if err != nil {
 		log.Println(err)
 	}
 }
 
+func (s *DataService) SendDelay() time.Duration {
+	return s.cfg.GetSendDelay()
+}
+
 func (s *DataService) GetActiveToken() (string, error) {
 	authSvc := auth_google.NewGoogleAuthService()
 	token, err := authSvc.GetActiveToken(&s.cfg.Token)
@@ -186,21 +398,40 @@ func (s *DataService) fetchMessages() error {
 		return err
 	}
 
-	for _, m := range msginfos {
-		log.Printf("Retrieving msg %d of size %d ready\n", m.Id, m.Size)
-		msg, err := conn.Retr(m.Id)
+	for _, info := range msginfos {
+		log.Printf("Retrieving msg %d of size %d ready\n", info.Id, info.Size)
+		msg, err := conn.Retr(info.Id)
 		if err != nil {
 			log.Printf("error: %v", err)
 		} else {
-			m := s.processMessage(msg)
-			if _, ok := s.existingMsgsIds[m.Id]; !ok {
-				err := storage.SaveMessage(s.db, m)
-				if err != nil {
-					log.Println(err)
-				}
-				s.msgChan <- m
-				s.existingMsgsIds[m.Id] = struct{}{}
+			m, data, err := s.processMessage(msg)
+			if err != nil {
+				log.Printf("skipping msg %d: %v", info.Id, err)
+				continue
+			}
+			if s.isKnown(m.Id) {
+				continue
+			}
+			// a message stored by a previous run, or sent from mchat
+			// meanwhile, is neither saved nor shown again
+			inserted, err := storage.SaveMessage(s.db, m)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			s.addKnown(m.Id)
+			if !inserted {
+				continue
+			}
+			s.updateAutocrypt(msg, m)
+			s.updateList(msg, m)
+			if err := s.seeAddresses(m); err != nil {
+				log.Println(err)
+			}
+			if err := storage.SaveAttachments(s.db, m, data); err != nil {
+				log.Println(err)
 			}
+			s.msgChan <- m
 		}
 	}
 
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"fmt"
+	"mchat/internal/models"
+	"slices"
+	"testing"
+)
+
+func TestReplySubject(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", defaultSubject},
+		{"   ", defaultSubject},
+		{"Lunch", "Re: Lunch"},
+		{"  Lunch  ", "Re: Lunch"},
+		{"Re: Lunch", "Re: Lunch"},
+		{"RE: Lunch", "RE: Lunch"},
+		{"re:Lunch", "re:Lunch"},
+		{"Fwd: Lunch", "Re: Fwd: Lunch"},
+		{"Rebooking", "Re: Rebooking"},
+	}
+	for _, tt := range tests {
+		if got := replySubject(tt.in); got != tt.want {
+			t.Errorf("replySubject(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestThreadReferences(t *testing.T) {
+	first := &models.Message{MessageId: "<1@example.com>"}
+	if got := threadReferences(first); !slices.Equal(got, []string{"<1@example.com>"}) {
+		t.Errorf("references of the first message = %q", got)
+	}
+
+	refs := []string{"<1@example.com>", "<2@example.com>"}
+	reply := &models.Message{MessageId: "<3@example.com>", References: refs}
+	got := threadReferences(reply)
+	if !slices.Equal(got, []string{"<1@example.com>", "<2@example.com>", "<3@example.com>"}) {
+		t.Errorf("references = %q", got)
+	}
+	if len(reply.References) != 2 {
+		t.Errorf("the references of the message were modified: %q", reply.References)
+	}
+
+	// a long thread keeps its root and the latest ancestors
+	var long []string
+	for i := 1; i <= 30; i++ {
+		long = append(long, fmt.Sprintf("<%d@example.com>", i))
+	}
+	got = threadReferences(&models.Message{MessageId: "<31@example.com>", References: long})
+	if len(got) != maxReferences {
+		t.Fatalf("%d references, want %d", len(got), maxReferences)
+	}
+	if got[0] != "<1@example.com>" || got[1] != "<13@example.com>" || got[len(got)-1] != "<31@example.com>" {
+		t.Errorf("references = %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"mchat/internal/models"
+	"mchat/internal/smime"
+	"mchat/pkg/compose"
+	"strings"
+)
+
+const pgpSignature = "application/pgp-signature"
+
+// signatureProtocols are the multipart/signed protocols that are verified
+var signatureProtocols = map[string]bool{
+	pgpSignature:                    true,
+	"application/pkcs7-signature":   true,
+	"application/x-pkcs7-signature": true,
+}
+
+// isOpaqueSigned tells whether a part is an S/MIME signed-data object
+// wrapping the signed entity
+func isOpaqueSigned(mediaType string, params map[string]string) bool {
+	return (mediaType == "application/pkcs7-mime" || mediaType == "application/x-pkcs7-mime") &&
+		strings.EqualFold(params["smime-type"], "signed-data")
+}
+
+// parseOpaqueSigned verifies a signed-data object and parses the entity it
+// wraps
+func (b *mailBody) parseOpaqueSigned(body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	content, v, err := b.certs.VerifyOpaque(data)
+	if err != nil {
+		return err
+	}
+	b.verification = v
+	return b.parseEntity(content)
+}
+
+// signBody replaces body by its S/MIME signature with the identity of the
+// configuration
+func (s *DataService) signBody(body *compose.Part, m *models.Message) (*compose.Part, error) {
+	id, err := smime.LoadIdentity(s.cfg.SMIMEIdentity, s.cfg.SMIMEIdentityPassword)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if _, err := body.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	der, err := id.Sign(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	m.Signature = models.SignatureValid
+	m.Signer = id.Name()
+	return compose.SignedPart(body, "sha-256", compose.SMIMESignaturePart(der)), nil
+}
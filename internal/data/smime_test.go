@@ -0,0 +1,93 @@
+package data
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"mchat/internal/models"
+	"mchat/internal/smime"
+	"mchat/pkg/compose"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseBodySMIME(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Anna Kowalska"},
+		EmailAddresses:        []string{"anna@example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	trustStore := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(trustStore, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	certs, err := smime.NewVerifier(trustStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := compose.MultipartPart("alternative", compose.FlowedTextPart("Signed hello"), compose.HTMLPart("<p>Signed hello</p>"))
+	var entity bytes.Buffer
+	if _, err := signed.WriteTo(&entity); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := (&smime.Identity{Certificate: cert, Key: key}).Sign(entity.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &compose.Message{
+		From: &mail.Address{Address: "anna@example.com"},
+		To:   []*mail.Address{{Address: "bob@example.org"}},
+		Body: compose.SignedPart(signed, "sha-256", compose.SMIMESignaturePart(signature)),
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, verifier := range map[string]*smime.Verifier{"trusted": certs, "untrusted": nil} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := parseBody(parsed, nil, verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := models.SignatureValid
+			if verifier == nil {
+				want = models.SignatureUnknownKey
+			}
+			if v := body.verification; v.Status != want || v.Signer != "Anna Kowalska" {
+				t.Errorf("verification = %+v, want %v", v, want)
+			}
+			if body.text != "Signed hello" || len(body.attachments) != 0 {
+				t.Errorf("text = %q, attachments = %v", body.text, body.attachments)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
 type MsgStatus int
 
@@ -8,8 +12,43 @@ const (
 	MsgStatusSuccess MsgStatus = iota
 	MsgStatusSending
 	MsgStatusError
+	MsgStatusQueued
+)
+
+// SignatureStatus is the outcome of checking the signature of a message
+type SignatureStatus int
+
+const (
+	SignatureNone SignatureStatus = iota
+	SignatureValid
+	// SignatureInvalid is a signature that doesn't match the content or
+	// the sender
+	SignatureInvalid
+	// SignatureUnknownKey is a signature made with a key not in the keyring
+	SignatureUnknownKey
 )
 
+// AuthStatus is the verdict of the receiving server on whether the sender
+// address is genuine, from its DKIM, SPF and DMARC checks
+type AuthStatus int
+
+const (
+	AuthNone AuthStatus = iota
+	AuthPass
+	// AuthFail is a message whose sender address is likely forged
+	AuthFail
+)
+
+// Verification is the outcome of checking a signature
+type Verification struct {
+	Status SignatureStatus
+	// Signer names the signing key or certificate, or identifies it when
+	// it is unknown
+	Signer string
+	// Emails the key or certificate is issued to
+	Emails []string
+}
+
 type Message struct {
 	Id          string
 	From        string
@@ -19,10 +58,146 @@ type Message struct {
 	Content     string
 	Date        time.Time
 	Status      MsgStatus
+	// Outgoing is a message sent by the user
+	Outgoing bool
+
+	// Recipients are the To and Cc addresses, To only holds the first one
+	Recipients []string
+	// ReplyTo are the Reply-To addresses in the domain of the sender, the
+	// chat of an incoming message is theirs when there are any
+	ReplyTo []string
+
+	// Body is the full text including the quoted history and signature,
+	// Content only the reply written by the sender
+	Body string
+
+	// threading
+	MessageId  string
+	InReplyTo  string
+	References []string
+	Subject    string
+
+	Attachments []*Attachment
+
+	// Calendar is the raw iCalendar of an invitation or of its reply,
+	// Event the event it describes
+	Calendar string
+	Event    *Event
+
+	// VCard is the raw vCard shared in the message, Card the contact it
+	// describes
+	VCard string
+	Card  *Contact
+
+	// end-to-end security
+	Encrypted bool
+	Signature SignatureStatus
+	// Signer names the key or certificate the message is signed with
+	Signer string
+
+	// sender authentication, AuthResults lists the checks of the receiving
+	// server such as "dkim=pass spf=pass dmarc=pass"
+	Auth        AuthStatus
+	AuthResults string
 }
 
+type Attachment struct {
+	Name        string
+	ContentType string
+	Size        int64
+	ContentId   string
+	// Hash addresses the content in the attachments store
+	Hash string
+	// Path of the file on the local disk
+	Path string
+}
+
+// Event is a meeting scheduled by an iTIP message
+type Event struct {
+	// Method is REQUEST for an invitation, CANCEL or REPLY
+	Method       string
+	UID          string
+	Sequence     int
+	RecurrenceId time.Time
+	Summary      string
+	Location     string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Cancelled    bool
+	Organizer    *Attendee
+	Attendees    []*Attendee
+}
+
+type Attendee struct {
+	Name    string
+	Address string
+	// Status is the iCalendar participation status, e.g. ACCEPTED
+	Status string
+}
+
+// Contact is a person of the address book, the first email address is the
+// one chats are started with
+type Contact struct {
+	Name         string
+	Emails       []string
+	Phones       []string
+	Organization string
+}
+
+// Chat is a conversation with one or more participants, Address is the
+// address of the only one, the group key of several, see ChatKey, or the
+// key of a mailing list channel, see ChannelKey
 type Chat struct {
 	Address  string
 	Name     string
 	Messages []*Message
 }
+
+// groupSeparator separates the participants of a group key
+const groupSeparator = ","
+
+// ChatKey returns the address of the chat with the participants, other than
+// the user, in their normalized form: the address of the only one, or for a
+// group the sorted addresses joined by commas so that it doesn't depend on
+// the order of the headers
+func ChatKey(participants []string) string {
+	keys := slices.Clone(participants)
+	slices.Sort(keys)
+	return strings.Join(slices.Compact(keys), groupSeparator)
+}
+
+// Participants returns the addresses of a chat
+func Participants(chatAddress string) []string {
+	return strings.Split(chatAddress, groupSeparator)
+}
+
+// IsGroup tells whether the chat has several participants
+func IsGroup(chatAddress string) bool {
+	return strings.Contains(chatAddress, groupSeparator)
+}
+
+// channelPrefix starts the chat address of a mailing list
+const channelPrefix = "list:"
+
+// ChannelKey returns the chat address of the mailing list with the List-Id
+func ChannelKey(listId string) string {
+	return channelPrefix + listId
+}
+
+// ChannelId returns the List-Id of a channel, and whether the chat is one
+func ChannelId(chatAddress string) (string, bool) {
+	return strings.CutPrefix(chatAddress, channelPrefix)
+}
+
+// IsChannel tells whether the chat gathers the messages of a mailing list
+func IsChannel(chatAddress string) bool {
+	return strings.HasPrefix(chatAddress, channelPrefix)
+}
+
+// ChatSettings are the per chat preferences
+type ChatSettings struct {
+	// Encrypt sends the messages of the chat with OpenPGP
+	Encrypt bool
+}
@@ -0,0 +1,541 @@
+// Package pgp keeps the OpenPGP keys of the user and their peers and
+// implements the cryptographic operations of PGP/MIME, RFC 3156.
+package pgp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mchat/internal/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/adrg/xdg"
+)
+
+// Keyring is the local store of OpenPGP keys, kept as binary packets in the
+// XDG data directory. Secret keys are stored as they were imported: those
+// protected by a passphrase stay encrypted on disk and are unlocked in
+// memory for the session, see Unlock.
+type Keyring struct {
+	path string
+
+	mu       sync.RWMutex
+	entities openpgp.EntityList
+	// unlocked copies of the protected secret keys by fingerprint, they are
+	// never saved
+	unlocked map[string]*openpgp.Entity
+}
+
+// Passphrase returns the passphrase unlocking the secret key of e
+type Passphrase func(e *openpgp.Entity) ([]byte, error)
+
+func defaultPath() (string, error) {
+	path := filepath.Join(xdg.DataHome, "mchat", "keyring.pgp")
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	return path, err
+}
+
+// Open loads the keyring of the user
+func Open() (*Keyring, error) {
+	path, err := defaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(path)
+}
+
+// OpenFile loads the keyring stored at path, a missing file is an empty
+// keyring
+func OpenFile(path string) (*Keyring, error) {
+	k := &Keyring{path: path, unlocked: make(map[string]*openpgp.Entity)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	k.entities, err = openpgp.ReadKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return k, nil
+}
+
+// Fingerprint returns the hex fingerprint of the primary key of e
+func Fingerprint(e *openpgp.Entity) string {
+	return strings.ToUpper(hex.EncodeToString(e.PrimaryKey.Fingerprint))
+}
+
+// Emails returns the email addresses of the identities of e
+func Emails(e *openpgp.Entity) []string {
+	var emails []string
+	now := time.Now()
+	for _, id := range e.Identities {
+		if id.UserId.Email != "" && !id.Revoked(now) {
+			emails = append(emails, id.UserId.Email)
+		}
+	}
+	return emails
+}
+
+// Describe returns a one-line summary of e: fingerprint, user id and
+// whether the secret key is known
+func Describe(e *openpgp.Entity) string {
+	s := Fingerprint(e)
+	if id := e.PrimaryIdentity(); id != nil {
+		s += "  " + id.Name
+	}
+	if e.PrivateKey != nil {
+		s += "  [secret]"
+	}
+	if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+		s += " [locked]"
+	}
+	return s
+}
+
+func hasEmail(e *openpgp.Entity, email string) bool {
+	for _, addr := range Emails(e) {
+		if strings.EqualFold(addr, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entities returns the keys of the keyring
+func (k *Keyring) Entities() openpgp.EntityList {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append(openpgp.EntityList(nil), k.entities...)
+}
+
+// PublicKey returns a key able to encrypt to email, or nil. The most
+// recently added key is preferred.
+func (k *Keyring) PublicKey(email string) *openpgp.Entity {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	for _, e := range slices.Backward(k.entities) {
+		if _, ok := e.EncryptionKey(now); ok && !e.Revoked(now) && hasEmail(e, email) {
+			return e
+		}
+	}
+	return nil
+}
+
+// SecretKey returns the unlocked key signing for email, or nil. The most
+// recently added key is preferred, protected keys count once unlocked.
+func (k *Keyring) SecretKey(email string) *openpgp.Entity {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	for _, e := range slices.Backward(k.keys()) {
+		if e.PrivateKey == nil || e.PrivateKey.Encrypted || e.Revoked(now) {
+			continue
+		}
+		if _, ok := e.SigningKey(now); ok && hasEmail(e, email) {
+			return e
+		}
+	}
+	return nil
+}
+
+// LockedKey returns the secret key of email still locked, or nil
+func (k *Keyring) LockedKey(email string) *openpgp.Entity {
+	for _, e := range slices.Backward(k.Locked()) {
+		if hasEmail(e, email) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Locked returns the protected secret keys not unlocked yet
+func (k *Keyring) Locked() openpgp.EntityList {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var locked openpgp.EntityList
+	for _, e := range k.entities {
+		if e.PrivateKey != nil && e.PrivateKey.Encrypted && k.unlocked[Fingerprint(e)] == nil {
+			locked = append(locked, e)
+		}
+	}
+	return locked
+}
+
+// keys returns the keys with the protected secret keys unlocked so far
+func (k *Keyring) keys() openpgp.EntityList {
+	keys := make(openpgp.EntityList, len(k.entities))
+	for i, e := range k.entities {
+		if u := k.unlocked[Fingerprint(e)]; u != nil {
+			e = u
+		}
+		keys[i] = e
+	}
+	return keys
+}
+
+// Unlock unlocks the protected secret keys for the session, an empty
+// passphrase leaves a key locked
+func (k *Keyring) Unlock(passphrase Passphrase) error {
+	for _, e := range k.Locked() {
+		pass, err := passphrase(e)
+		if err != nil {
+			return err
+		}
+		if len(pass) == 0 {
+			continue
+		}
+		if err := k.unlock(e, pass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlock decrypts a copy of the protected secret key e, the key stays
+// encrypted in the keyring
+func (k *Keyring) unlock(e *openpgp.Entity, pass []byte) error {
+	u, err := clone(e)
+	if err != nil {
+		return err
+	}
+	if err := u.DecryptPrivateKeys(pass); err != nil {
+		return fmt.Errorf("unlocking %s: %w", Fingerprint(e), err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.unlocked[Fingerprint(e)] = u
+	return nil
+}
+
+// clone copies e with its secret key as it is, locked or not
+func clone(e *openpgp.Entity) (*openpgp.Entity, error) {
+	var b bytes.Buffer
+	if err := e.SerializePrivateWithoutSigning(&b, nil); err != nil {
+		return nil, err
+	}
+	return openpgp.ReadEntity(packet.NewReader(&b))
+}
+
+// Import adds the armored or binary keys of r to the keyring. The protected
+// secret keys are stored as they are, the passphrase unlocks them for the
+// session.
+func (k *Keyring) Import(r io.Reader, passphrase Passphrase) (openpgp.EntityList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entities {
+		if e.PrivateKey == nil || !e.PrivateKey.Encrypted {
+			continue
+		}
+		if passphrase == nil {
+			return nil, fmt.Errorf("the secret key %s is locked", Fingerprint(e))
+		}
+		pass, err := passphrase(e)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.unlock(e, pass); err != nil {
+			return nil, err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, e := range entities {
+		k.add(e)
+	}
+	return entities, k.save()
+}
+
+// Add stores e in the keyring, replacing the key with the same fingerprint
+func (k *Keyring) Add(e *openpgp.Entity) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.add(e)
+	return k.save()
+}
+
+func (k *Keyring) add(e *openpgp.Entity) {
+	fp := Fingerprint(e)
+	for i, old := range k.entities {
+		if Fingerprint(old) != fp {
+			continue
+		}
+		// a public key update keeps the secret key already known
+		if e.PrivateKey == nil && old.PrivateKey != nil {
+			return
+		}
+		if e.PrivateKey != nil && !e.PrivateKey.Encrypted {
+			delete(k.unlocked, fp)
+		}
+		k.entities[i] = e
+		return
+	}
+	k.entities = append(k.entities, e)
+}
+
+func (k *Keyring) save() error {
+	var b bytes.Buffer
+	for _, e := range k.entities {
+		var err error
+		if e.PrivateKey != nil {
+			err = e.SerializePrivateWithoutSigning(&b, nil)
+		} else {
+			err = e.Serialize(&b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// Generate creates a signing and encryption key for the user and adds it
+// to the keyring
+func (k *Keyring) Generate(name, email string) (*openpgp.Entity, error) {
+	e, err := openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		return nil, err
+	}
+	return e, k.Add(e)
+}
+
+// ReadKey parses the binary public key of a peer, it must be able to
+// encrypt
+func ReadKey(data []byte) (*openpgp.Entity, error) {
+	e, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := e.EncryptionKey(time.Now()); !ok {
+		return nil, fmt.Errorf("the key %s can't encrypt", Fingerprint(e))
+	}
+	return e, nil
+}
+
+// PublicKeyData returns the binary public key of e
+func PublicKeyData(e *openpgp.Entity) ([]byte, error) {
+	var b bytes.Buffer
+	err := e.Serialize(&b)
+	return b.Bytes(), err
+}
+
+// Find returns the keys with an email address or a fingerprint (or key id)
+// matching query
+func (k *Keyring) Find(query string) openpgp.EntityList {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var found openpgp.EntityList
+	q := strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(query, " ", ""), "0x"))
+	for _, e := range k.entities {
+		if hasEmail(e, query) || (len(q) >= 8 && strings.HasSuffix(Fingerprint(e), q)) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Export writes the armored keys matching query, with their secret keys
+// when secret is set. The secret keys are exported protected: as stored
+// when they are, otherwise encrypted with the passphrase.
+func (k *Keyring) Export(w io.Writer, query string, secret bool, passphrase Passphrase) error {
+	entities := k.Find(query)
+	if len(entities) == 0 {
+		return fmt.Errorf("no key found for %q", query)
+	}
+	blockType := openpgp.PublicKeyType
+	if secret {
+		blockType = openpgp.PrivateKeyType
+	}
+	aw, err := armor.Encode(w, blockType, nil)
+	if err != nil {
+		return err
+	}
+	for _, e := range entities {
+		if secret {
+			if e.PrivateKey == nil {
+				return fmt.Errorf("the secret key of %s is unknown", Fingerprint(e))
+			}
+			e, err = protect(e, passphrase)
+			if err != nil {
+				return err
+			}
+			err = e.SerializePrivateWithoutSigning(aw, nil)
+		} else {
+			err = e.Serialize(aw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// protect returns e with its secret key encrypted, e itself when it is
+// already protected
+func protect(e *openpgp.Entity, passphrase Passphrase) (*openpgp.Entity, error) {
+	if e.PrivateKey.Encrypted {
+		return e, nil
+	}
+	if passphrase == nil {
+		return nil, fmt.Errorf("the secret key %s needs a passphrase to be exported", Fingerprint(e))
+	}
+	pass, err := passphrase(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("the secret key %s needs a passphrase to be exported", Fingerprint(e))
+	}
+	p, err := clone(e)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.EncryptPrivateKeys(pass, nil)
+}
+
+// Encrypt encrypts data to the recipient keys and to the user's own key,
+// and signs it when the user has a secret key. The armored message is
+// returned with whether it is signed.
+func (k *Keyring) Encrypt(data []byte, to []*openpgp.Entity, user string) ([]byte, bool, error) {
+	signer := k.SecretKey(user)
+	if signer != nil {
+		// keep a readable copy of the sent messages
+		to = append(to[:len(to):len(to)], signer)
+	}
+
+	var b bytes.Buffer
+	aw, err := armor.Encode(&b, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	w, err := openpgp.Encrypt(aw, to, signer, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, false, err
+	}
+	b.WriteString("\n")
+	return b.Bytes(), signer != nil, nil
+}
+
+func verification(signer *openpgp.Entity, keyId uint64, err error) models.Verification {
+	if signer == nil {
+		return models.Verification{Status: models.SignatureUnknownKey, Signer: fmt.Sprintf("%016X", keyId)}
+	}
+	v := models.Verification{Status: models.SignatureValid, Emails: Emails(signer)}
+	if id := signer.PrimaryIdentity(); id != nil {
+		v.Signer = id.Name
+	}
+	if err != nil {
+		v.Status = models.SignatureInvalid
+	}
+	return v
+}
+
+// Decrypt decrypts an armored message and verifies its signature
+func (k *Keyring) Decrypt(r io.Reader) ([]byte, models.Verification, error) {
+	block, err := armor.Decode(r)
+	if err != nil {
+		return nil, models.Verification{}, err
+	}
+	k.mu.RLock()
+	keys := k.keys()
+	k.mu.RUnlock()
+	md, err := openpgp.ReadMessage(block.Body, keys, nil, nil)
+	if err != nil {
+		return nil, models.Verification{}, err
+	}
+	data, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil && md.SignatureError == nil {
+		return nil, models.Verification{}, err
+	}
+	if !md.IsSigned {
+		return data, models.Verification{}, nil
+	}
+	var signer *openpgp.Entity
+	if md.SignedBy != nil {
+		signer = md.SignedBy.Entity
+	}
+	return data, verification(signer, md.SignedByKeyId, md.SignatureError), nil
+}
+
+// Verify checks the armored detached signature of signed
+func (k *Keyring) Verify(signed []byte, signature io.Reader) models.Verification {
+	block, err := armor.Decode(signature)
+	if err != nil {
+		return models.Verification{Status: models.SignatureInvalid}
+	}
+	sig, err := io.ReadAll(block.Body)
+	if err != nil {
+		return models.Verification{Status: models.SignatureInvalid}
+	}
+	_, signer, err := openpgp.VerifyDetachedSignature(k.Entities(), bytes.NewReader(signed), bytes.NewReader(sig), nil)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return verification(nil, issuerKeyId(sig), nil)
+	}
+	if signer == nil {
+		return models.Verification{Status: models.SignatureInvalid}
+	}
+	return verification(signer, 0, err)
+}
+
+func issuerKeyId(sig []byte) uint64 {
+	p, err := packet.Read(bytes.NewReader(sig))
+	if err != nil {
+		return 0
+	}
+	if s, ok := p.(*packet.Signature); ok && s.IssuerKeyId != nil {
+		return *s.IssuerKeyId
+	}
+	return 0
+}
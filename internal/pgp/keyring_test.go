@@ -0,0 +1,251 @@
+package pgp
+
+import (
+	"bytes"
+	"mchat/internal/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newEntity(t *testing.T, name, email string) *openpgp.Entity {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// publicKey returns e without its secret key, as a peer would import it
+func publicKey(t *testing.T, e *openpgp.Entity) *openpgp.Entity {
+	t.Helper()
+	var b bytes.Buffer
+	if err := e.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := openpgp.ReadEntity(packet.NewReader(&b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@example.com")
+	bob := newEntity(t, "Bob", "bob@example.org")
+
+	sender, err := OpenFile(filepath.Join(t.TempDir(), "keyring.pgp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Add(alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Add(publicKey(t, bob)); err != nil {
+		t.Fatal(err)
+	}
+
+	if sender.PublicKey("carol@example.net") != nil {
+		t.Error("found a key for a peer without one")
+	}
+	to := sender.PublicKey("BOB@example.org")
+	if to == nil {
+		t.Fatal("no key for bob")
+	}
+	armored, signed, err := sender.Encrypt([]byte("hello Bob"), []*openpgp.Entity{to}, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed {
+		t.Error("the message is not signed")
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring.pgp")
+	recipient, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient.Add(bob)
+
+	// the recipient doesn't know the key of the sender yet
+	data, v, err := recipient.Decrypt(bytes.NewReader(armored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello Bob" || v.Status != models.SignatureUnknownKey {
+		t.Errorf("got %q, %+v", data, v)
+	}
+
+	var exported bytes.Buffer
+	if err := sender.Export(&exported, "alice@example.com", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recipient.Import(&exported, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the keyring is saved and reloaded
+	recipient, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipient.Entities()) != 2 || recipient.SecretKey("bob@example.org") == nil {
+		t.Fatalf("keyring = %v", recipient.Entities())
+	}
+	_, v, err = recipient.Decrypt(bytes.NewReader(armored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Status != models.SignatureValid || v.Signer != "Alice <alice@example.com>" {
+		t.Errorf("verification = %+v", v)
+	}
+	if len(v.Emails) != 1 || v.Emails[0] != "alice@example.com" {
+		t.Errorf("emails = %v", v.Emails)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@example.com")
+	k, err := OpenFile(filepath.Join(t.TempDir(), "keyring.pgp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := []byte("Content-Type: text/plain\r\n\r\nsigned text\r\n")
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, alice, bytes.NewReader(signed), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	v := k.Verify(signed, bytes.NewReader(sig.Bytes()))
+	if v.Status != models.SignatureUnknownKey || v.Signer == "" {
+		t.Errorf("unknown key: %+v", v)
+	}
+	k.Add(publicKey(t, alice))
+	if v := k.Verify(signed, bytes.NewReader(sig.Bytes())); v.Status != models.SignatureValid {
+		t.Errorf("valid signature: %+v", v)
+	}
+	tampered := bytes.Replace(signed, []byte("signed"), []byte("forged"), 1)
+	if v := k.Verify(tampered, bytes.NewReader(sig.Bytes())); v.Status != models.SignatureInvalid {
+		t.Errorf("tampered content: %+v", v)
+	}
+}
+
+func passphrase(pass string) Passphrase {
+	return func(*openpgp.Entity) ([]byte, error) { return []byte(pass), nil }
+}
+
+func TestProtectedSecretKey(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@example.com")
+	if err := alice.EncryptPrivateKeys([]byte("correct horse"), nil); err != nil {
+		t.Fatal(err)
+	}
+	var armored bytes.Buffer
+	aw, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.SerializePrivateWithoutSigning(aw, nil); err != nil {
+		t.Fatal(err)
+	}
+	aw.Close()
+
+	path := filepath.Join(t.TempDir(), "keyring.pgp")
+	k, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Import(bytes.NewReader(armored.Bytes()), passphrase("wrong")); err == nil {
+		t.Fatal("imported with a wrong passphrase")
+	}
+	if _, err := k.Import(bytes.NewReader(armored.Bytes()), passphrase("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+	// unlocked for the session of the import
+	if k.SecretKey("alice@example.com") == nil || len(k.Locked()) != 0 {
+		t.Error("the imported key is not unlocked")
+	}
+
+	// but stored encrypted
+	k, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.SecretKey("alice@example.com") != nil || k.LockedKey("alice@example.com") == nil {
+		t.Fatal("the stored key is not locked")
+	}
+	if err := k.Unlock(passphrase("")); err != nil || len(k.Locked()) != 1 {
+		t.Errorf("an empty passphrase should leave the key locked: %v", err)
+	}
+	if err := k.Unlock(passphrase("wrong")); err == nil {
+		t.Error("unlocked with a wrong passphrase")
+	}
+	if err := k.Unlock(passphrase("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+	if k.SecretKey("alice@example.com") == nil {
+		t.Fatal("the key is not unlocked")
+	}
+	armoredMsg, signed, err := k.Encrypt([]byte("note to self"), nil, "alice@example.com")
+	if err != nil || !signed {
+		t.Fatalf("signed %v, %v", signed, err)
+	}
+	if data, _, err := k.Decrypt(bytes.NewReader(armoredMsg)); err != nil || string(data) != "note to self" {
+		t.Errorf("decrypted %q, %v", data, err)
+	}
+	if err := k.Add(publicKey(t, newEntity(t, "Bob", "bob@example.org"))); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := OpenFile(path); err != nil || reloaded.LockedKey("alice@example.com") == nil {
+		t.Errorf("saving the keyring unprotected the key: %v", err)
+	}
+
+	// exported as stored
+	var exported bytes.Buffer
+	if err := k.Export(&exported, "alice@example.com", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(&exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entities[0].PrivateKey.Encrypted || entities[0].DecryptPrivateKeys([]byte("correct horse")) != nil {
+		t.Error("the exported key is not protected by its passphrase")
+	}
+}
+
+func TestExportProtectsSecretKey(t *testing.T) {
+	k, err := OpenFile(filepath.Join(t.TempDir(), "keyring.pgp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Generate("Alice", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var exported bytes.Buffer
+	if err := k.Export(&exported, "alice@example.com", true, nil); err == nil {
+		t.Error("exported a secret key without passphrase")
+	}
+	if err := k.Export(&exported, "alice@example.com", true, passphrase("")); err == nil {
+		t.Error("exported a secret key with an empty passphrase")
+	}
+
+	exported.Reset()
+	if err := k.Export(&exported, "alice@example.com", true, passphrase("export")); err != nil {
+		t.Fatal(err)
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(&exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entities[0].PrivateKey.Encrypted || entities[0].DecryptPrivateKeys([]byte("export")) != nil {
+		t.Error("the exported key is not protected by the passphrase")
+	}
+	// the key of the keyring is left as it was
+	if k.SecretKey("alice@example.com") == nil {
+		t.Error("exporting locked the key of the keyring")
+	}
+}
@@ -0,0 +1,176 @@
+// Package smime verifies and creates the S/MIME signatures of RFC 8551,
+// detached (multipart/signed) or wrapping the content (signed-data).
+package smime
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"mchat/internal/models"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/smallstep/pkcs7"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// oidEmailAddress is the emailAddress attribute of older certificates
+// subjects, PKCS #9
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// Verifier checks signatures against a set of trusted root certificates, a
+// nil Verifier trusts no certificate
+type Verifier struct {
+	roots *x509.CertPool
+}
+
+// NewVerifier loads the trusted roots from a PEM file or a directory of PEM
+// files, the system roots are used when trustStore is empty
+func NewVerifier(trustStore string) (*Verifier, error) {
+	if trustStore == "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, err
+		}
+		return &Verifier{roots: roots}, nil
+	}
+
+	files := []string{trustStore}
+	if info, err := os.Stat(trustStore); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(trustStore, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	roots := x509.NewCertPool()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate in %s", f)
+		}
+	}
+	return &Verifier{roots: roots}, nil
+}
+
+// Emails returns the email addresses a certificate is issued to
+func Emails(cert *x509.Certificate) []string {
+	emails := slices.Clone(cert.EmailAddresses)
+	for _, name := range cert.Subject.Names {
+		if email, ok := name.Value.(string); ok && name.Type.Equal(oidEmailAddress) {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// Describe names the subject of a certificate
+func Describe(cert *x509.Certificate) string {
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = cert.Subject.String()
+	}
+	if o := cert.Subject.Organization; len(o) > 0 && o[0] != name {
+		name += ", " + o[0]
+	}
+	return name
+}
+
+// VerifyDetached checks the DER signature of a multipart/signed message,
+// signed is the canonical first part
+func (v *Verifier) VerifyDetached(signed, signature []byte) models.Verification {
+	p7, err := pkcs7.Parse(signature)
+	if err != nil {
+		return models.Verification{Status: models.SignatureInvalid}
+	}
+	p7.Content = signed
+	return v.verify(p7)
+}
+
+// VerifyOpaque checks a signed-data object and returns the signed content
+func (v *Verifier) VerifyOpaque(data []byte) ([]byte, models.Verification, error) {
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, models.Verification{}, err
+	}
+	return p7.Content, v.verify(p7), nil
+}
+
+func (v *Verifier) verify(p7 *pkcs7.PKCS7) models.Verification {
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return models.Verification{Status: models.SignatureInvalid}
+	}
+	result := models.Verification{Status: models.SignatureValid, Signer: Describe(signer), Emails: Emails(signer)}
+	if err := p7.Verify(); err != nil {
+		result.Status = models.SignatureInvalid
+		return result
+	}
+
+	if v == nil {
+		result.Status = models.SignatureUnknownKey
+		return result
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range p7.Certificates {
+		if c != signer {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := signer.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	})
+	if err != nil {
+		// a good signature by a certificate that isn't trusted
+		result.Status = models.SignatureUnknownKey
+	}
+	return result
+}
+
+// Identity is a certificate and its private key signing the user's messages
+type Identity struct {
+	Certificate *x509.Certificate
+	Key         crypto.PrivateKey
+	// Chain holds the intermediate certificates
+	Chain []*x509.Certificate
+}
+
+// LoadIdentity reads a PKCS #12 file
+func LoadIdentity(path, password string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &Identity{Certificate: cert, Key: key, Chain: chain}, nil
+}
+
+// Sign returns the detached DER signature of the canonical entity
+func (id *Identity) Sign(entity []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(entity)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(id.Certificate, id.Key, id.Chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+// Name describes the identity
+func (id *Identity) Name() string {
+	return Describe(id.Certificate)
+}
@@ -0,0 +1,144 @@
+package smime
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"mchat/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newIdentity returns a root certificate and an identity it issued to email
+func newIdentity(t *testing.T, email string) (*x509.Certificate, *Identity) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Example Root CA", Organization: []string{"Example"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "Anna Kowalska", Organization: []string{"Example"}},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(24 * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return ca, &Identity{Certificate: cert, Key: key}
+}
+
+func TestVerifyDetached(t *testing.T) {
+	ca, id := newIdentity(t, "anna@example.com")
+	entity := []byte("Content-Type: text/plain\r\n\r\nSigned text\r\n")
+	signature, err := id.Sign(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		signed   []byte
+		want     models.SignatureStatus
+	}{
+		{"trusted", &Verifier{roots: roots}, entity, models.SignatureValid},
+		{"untrusted root", &Verifier{roots: x509.NewCertPool()}, entity, models.SignatureUnknownKey},
+		{"no trust store", nil, entity, models.SignatureUnknownKey},
+		{"tampered", &Verifier{roots: roots}, []byte("Content-Type: text/plain\r\n\r\nForged text\r\n"), models.SignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.verifier.VerifyDetached(tt.signed, signature)
+			if v.Status != tt.want {
+				t.Errorf("status = %v, want %v", v.Status, tt.want)
+			}
+			if v.Signer != "Anna Kowalska, Example" || len(v.Emails) != 1 || v.Emails[0] != "anna@example.com" {
+				t.Errorf("signer = %q %v", v.Signer, v.Emails)
+			}
+		})
+	}
+}
+
+func TestVerifyOpaque(t *testing.T) {
+	ca, id := newIdentity(t, "anna@example.com")
+	entity := []byte("Content-Type: text/plain\r\n\r\nWrapped text\r\n")
+	sd, err := pkcs7.NewSignedData(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSigner(id.Certificate, id.Key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	content, v, err := (&Verifier{roots: roots}).VerifyOpaque(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(entity) || v.Status != models.SignatureValid {
+		t.Errorf("content = %q, verification = %+v", content, v)
+	}
+}
+
+func TestLoadIdentity(t *testing.T) {
+	ca, id := newIdentity(t, "anna@example.com")
+	p12, err := pkcs12.Modern.Encode(id.Key, id.Certificate, []*x509.Certificate{ca}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "identity.p12")
+	if err := os.WriteFile(path, p12, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadIdentity(path, "wrong"); err == nil {
+		t.Error("loaded with a wrong password")
+	}
+	loaded, err := LoadIdentity(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Name() != "Anna Kowalska, Example" || len(loaded.Chain) != 1 {
+		t.Errorf("identity = %s, chain of %d", loaded.Name(), len(loaded.Chain))
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetSeenAddresses returns the addresses the participants of the chats were
+// seen at, with the date of the latest message they sent from each
+func GetSeenAddresses(db *sql.DB) (map[string]time.Time, error) {
+	rows, err := db.Query(`SELECT address, last_seen FROM seen_addresses`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]time.Time)
+	for rows.Next() {
+		var address string
+		var lastSeen time.Time
+		if err := rows.Scan(&address, &lastSeen); err != nil {
+			return nil, err
+		}
+		seen[address] = lastSeen
+	}
+	return seen, rows.Err()
+}
+
+// SaveSeenAddress records a message sent from address at date, an older
+// message doesn't move the date back
+func SaveSeenAddress(db *sql.DB, address string, date time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO seen_addresses (address, last_seen) VALUES (?, ?)
+		ON CONFLICT (address) DO UPDATE SET last_seen = max(last_seen, excluded.last_seen)`,
+		address, formatDate(date),
+	)
+	return err
+}
@@ -0,0 +1,77 @@
+package storage
+
+import "database/sql"
+
+// GetAliases returns the chat address each alias is merged into, by alias
+func GetAliases(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(`SELECT address, chat_address FROM aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make(map[string]string)
+	for rows.Next() {
+		var address, chatAddress string
+		if err := rows.Scan(&address, &chatAddress); err != nil {
+			return nil, err
+		}
+		aliases[address] = chatAddress
+	}
+	return aliases, rows.Err()
+}
+
+// MergeChat makes alias an address of the chat into and moves the messages
+// of the chat of alias there
+func MergeChat(db *sql.DB, alias, into string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO aliases (address, chat_address) VALUES (?, ?)
+		ON CONFLICT (address) DO UPDATE SET chat_address = excluded.chat_address`,
+		alias, into,
+	)
+	if err != nil {
+		return err
+	}
+	if err := renameChat(tx, alias, into); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RenameChat moves the messages and the settings of the chat from to the
+// chat to, they merge when it exists
+func RenameChat(db *sql.DB, from, to string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := renameChat(tx, from, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func renameChat(tx *sql.Tx, from, to string) error {
+	queries := []string{
+		`UPDATE messages SET chat_address = ? WHERE chat_address = ?`,
+		// the settings of to win
+		`UPDATE OR IGNORE chat_settings SET chat_address = ? WHERE chat_address = ?`,
+		// the aliases of from follow it
+		`UPDATE aliases SET chat_address = ? WHERE chat_address = ?`,
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(q, to, from); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`DELETE FROM chat_settings WHERE chat_address = ?`, from)
+	return err
+}
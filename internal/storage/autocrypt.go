@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+	"mchat/pkg/autocrypt"
+)
+
+// GetAutocryptPeer returns the Autocrypt state of a peer, or nil if no
+// message from them was seen
+func GetAutocryptPeer(db *sql.DB, address string) (*autocrypt.Peer, error) {
+	p := autocrypt.Peer{Addr: address}
+	err := db.QueryRow(
+		`SELECT last_seen, autocrypt_timestamp, public_key, prefer_encrypt FROM autocrypt_peers WHERE address = ?`,
+		address,
+	).Scan(&p.LastSeen, &p.Timestamp, &p.KeyData, &p.PreferEncrypt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func SaveAutocryptPeer(db *sql.DB, p *autocrypt.Peer) error {
+	_, err := db.Exec(
+		`INSERT INTO autocrypt_peers (address, last_seen, autocrypt_timestamp, public_key, prefer_encrypt) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (address) DO UPDATE SET
+			last_seen = excluded.last_seen, autocrypt_timestamp = excluded.autocrypt_timestamp,
+			public_key = excluded.public_key, prefer_encrypt = excluded.prefer_encrypt`,
+		p.Addr, p.LastSeen, p.Timestamp, p.KeyData, p.PreferEncrypt,
+	)
+	return err
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"database/sql"
+	"mchat/internal/models"
+	"strings"
+)
+
+// GetContacts returns the address book sorted by name
+func GetContacts(db *sql.DB) ([]*models.Contact, error) {
+	rows, err := db.Query(`SELECT name, emails, phones, organization FROM contacts ORDER BY name COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []*models.Contact
+	for rows.Next() {
+		var c models.Contact
+		var emails, phones string
+		err := rows.Scan(&c.Name, &emails, &phones, &c.Organization)
+		if err != nil {
+			return nil, err
+		}
+		c.Emails = splitLines(emails)
+		c.Phones = splitLines(phones)
+		contacts = append(contacts, &c)
+	}
+	return contacts, rows.Err()
+}
+
+// SaveContact adds the contact to the address book, replacing the one with
+// the same first email address
+func SaveContact(db *sql.DB, c *models.Contact) error {
+	_, err := db.Exec(
+		`INSERT INTO contacts (address, name, emails, phones, organization) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (address) DO UPDATE SET
+			name = excluded.name, emails = excluded.emails,
+			phones = excluded.phones, organization = excluded.organization`,
+		c.Emails[0], c.Name, strings.Join(c.Emails, "\n"), strings.Join(c.Phones, "\n"), c.Organization,
+	)
+	return err
+}
+
+// splitLines splits a newline separated list, phone numbers contain spaces
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
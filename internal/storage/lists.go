@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"database/sql"
+	"mchat/pkg/mlist"
+	"strings"
+)
+
+// GetList returns the mailing list with the List-Id, or nil if no message
+// from it was seen
+func GetList(db *sql.DB, id string) (*mlist.List, error) {
+	l := mlist.List{Id: id}
+	var unsubscribe string
+	err := db.QueryRow(
+		`SELECT name, post, unsubscribe, one_click FROM lists WHERE id = ?`, id,
+	).Scan(&l.Name, &l.Post, &unsubscribe, &l.OneClick)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Unsubscribe = strings.Fields(unsubscribe)
+	return &l, nil
+}
+
+// GetLists returns the mailing lists by List-Id
+func GetLists(db *sql.DB) (map[string]*mlist.List, error) {
+	rows, err := db.Query(`SELECT id, name, post, unsubscribe, one_click FROM lists`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make(map[string]*mlist.List)
+	for rows.Next() {
+		var l mlist.List
+		var unsubscribe string
+		if err := rows.Scan(&l.Id, &l.Name, &l.Post, &unsubscribe, &l.OneClick); err != nil {
+			return nil, err
+		}
+		l.Unsubscribe = strings.Fields(unsubscribe)
+		lists[l.Id] = &l
+	}
+	return lists, rows.Err()
+}
+
+// SaveList records the headers of the latest message of a mailing list
+func SaveList(db *sql.DB, l *mlist.List) error {
+	_, err := db.Exec(
+		`INSERT INTO lists (id, name, post, unsubscribe, one_click) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name, post = excluded.post,
+			unsubscribe = excluded.unsubscribe, one_click = excluded.one_click`,
+		l.Id, l.Name, l.Post, strings.Join(l.Unsubscribe, " "), l.OneClick,
+	)
+	return err
+}
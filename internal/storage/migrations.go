@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations upgrade the schema in order, the version of a database stored
+// in PRAGMA user_version is the number of migrations applied to it. New
+// changes of the schema are appended, the released migrations never change.
+var migrations = []func(tx *sql.Tx) error{
+	createMessages,
+	threading,
+	attachmentsTable,
+	bodyColumn,
+	calendarColumn,
+	contactsTable,
+	openPGP,
+	autocryptPeers,
+	senderAuth,
+	groupRecipients,
+	mailingLists,
+	aliasesTable,
+	replyToColumn,
+	seenAddresses,
+	uniqueKeys,
+}
+
+// migrate applies the migrations a database lacks, each one in a
+// transaction with the version it brings the database to
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("the database is at version %d, this mchat only knows %d: it was opened by a newer mchat", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		if err := migrateTo(db, i+1); err != nil {
+			return fmt.Errorf("migrating the database to version %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func migrateTo(db *sql.DB, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := migrations[version-1](tx); err != nil {
+		return err
+	}
+	// PRAGMA takes no parameters
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumns adds columns to the messages table, each given by its name and
+// definition
+func addColumns(tx *sql.Tx, columns ...string) error {
+	for _, c := range columns {
+		if _, err := tx.Exec(`ALTER TABLE messages ADD COLUMN ` + c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createMessages is the schema of the first release, whose databases have
+// no version
+func createMessages(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS messages (
+		id TEXT,
+		from_addr TEXT,
+		to_addr TEXT,
+		contact TEXT,
+		chat_address TEXT,
+		content TEXT,
+		sent_date DATETIME
+	)`)
+	return err
+}
+
+// threading keeps the headers replies are threaded with and stores the
+// dates in UTC, the last message of a thread is looked up by date
+func threading(tx *sql.Tx) error {
+	err := addColumns(tx,
+		`message_id TEXT NOT NULL DEFAULT ''`,
+		`in_reply_to TEXT NOT NULL DEFAULT ''`,
+		`refs TEXT NOT NULL DEFAULT ''`,
+		`subject TEXT NOT NULL DEFAULT ''`,
+	)
+	if err != nil {
+		return err
+	}
+	return utcSentDates(tx)
+}
+
+// attachmentsTable records the attachments of the received messages, their
+// content is in the blob store
+func attachmentsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE attachments (
+		message_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		content_id TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL,
+		hash TEXT NOT NULL
+	)`)
+	return err
+}
+
+// bodyColumn keeps the full text of the messages with the quoted history
+func bodyColumn(tx *sql.Tx) error {
+	return addColumns(tx, `body TEXT NOT NULL DEFAULT ''`)
+}
+
+// calendarColumn keeps the invitations and their replies
+func calendarColumn(tx *sql.Tx) error {
+	return addColumns(tx, `calendar TEXT NOT NULL DEFAULT ''`)
+}
+
+// contactsTable keeps the shared vCards and the address book they are
+// imported into
+func contactsTable(tx *sql.Tx) error {
+	if err := addColumns(tx, `vcard TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE TABLE contacts (
+		address TEXT NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		emails TEXT NOT NULL,
+		phones TEXT NOT NULL,
+		organization TEXT NOT NULL DEFAULT ''
+	)`)
+	return err
+}
+
+// openPGP keeps the protection of the messages and whether a chat is
+// encrypted
+func openPGP(tx *sql.Tx) error {
+	err := addColumns(tx,
+		`encrypted INTEGER NOT NULL DEFAULT 0`,
+		`signature INTEGER NOT NULL DEFAULT 0`,
+		`signer TEXT NOT NULL DEFAULT ''`,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	CREATE TABLE chat_settings (
+		chat_address TEXT NOT NULL PRIMARY KEY,
+		encrypt INTEGER NOT NULL DEFAULT 0
+	)`)
+	return err
+}
+
+// autocryptPeers keeps the Autocrypt state of the senders
+func autocryptPeers(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE autocrypt_peers (
+		address TEXT NOT NULL PRIMARY KEY,
+		last_seen DATETIME NOT NULL,
+		autocrypt_timestamp DATETIME NOT NULL,
+		public_key BLOB,
+		prefer_encrypt TEXT NOT NULL DEFAULT 'nopreference'
+	)`)
+	return err
+}
+
+// senderAuth keeps the authentication verdict of the received messages
+func senderAuth(tx *sql.Tx) error {
+	return addColumns(tx,
+		`auth INTEGER NOT NULL DEFAULT 0`,
+		`auth_results TEXT NOT NULL DEFAULT ''`,
+	)
+}
+
+// groupRecipients keeps every recipient of the messages
+func groupRecipients(tx *sql.Tx) error {
+	return addColumns(tx, `recipients TEXT NOT NULL DEFAULT ''`)
+}
+
+// mailingLists keeps the headers of the lists and the direction of the
+// messages. The sender isn't a participant of the chats of the user's
+// messages.
+func mailingLists(tx *sql.Tx) error {
+	if err := addColumns(tx, `outgoing INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	UPDATE messages SET outgoing = instr(',' || lower(chat_address) || ',', ',' || lower(from_addr) || ',') = 0;
+	CREATE TABLE lists (
+		id TEXT NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		post TEXT NOT NULL,
+		unsubscribe TEXT NOT NULL,
+		one_click INTEGER NOT NULL DEFAULT 0
+	)`)
+	return err
+}
+
+// aliasesTable keeps the addresses merged into the chat of another
+func aliasesTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE aliases (
+		address TEXT NOT NULL PRIMARY KEY,
+		chat_address TEXT NOT NULL
+	)`)
+	return err
+}
+
+// utcSentDates rewrites the dates of the messages in UTC with dateFormat,
+// they were stored in the zone of the sender, which doesn't sort in time
+// order
+func utcSentDates(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT rowid, sent_date FROM messages`)
+	if err != nil {
+		return err
+	}
+	dates := make(map[int64]time.Time)
+	for rows.Next() {
+		var rowid int64
+		var date any
+		if err := rows.Scan(&rowid, &date); err != nil {
+			rows.Close()
+			return err
+		}
+		// the dates the driver doesn't recognize are left as they are
+		if t, ok := date.(time.Time); ok {
+			dates[rowid] = t
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for rowid, t := range dates {
+		if _, err := tx.Exec(`UPDATE messages SET sent_date = ? WHERE rowid = ?`, formatDate(t), rowid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replyToColumn stores the Reply-To addresses of the messages, the chat of
+// a message routed to them is derived again from them. The messages stored
+// before have none.
+func replyToColumn(tx *sql.Tx) error {
+	return addColumns(tx, `reply_to TEXT NOT NULL DEFAULT ''`)
+}
+
+// seenAddresses keeps the addresses as written in the messages, the chats
+// are keyed by the normalized ones which may not reach the person
+func seenAddresses(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE seen_addresses (
+		address TEXT NOT NULL PRIMARY KEY COLLATE NOCASE,
+		last_seen DATETIME NOT NULL
+	)`)
+	return err
+}
+
+// uniqueKeys makes the id the key of the messages and records each
+// attachment of a message once, the copies of a message stored twice are
+// dropped with their attachments. The messages without Message-ID have an
+// empty id and are left out. The indexes serve the loading of a chat in date
+// order and the lookup of its last message.
+func uniqueKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	DELETE FROM attachments WHERE message_id != '' AND rowid NOT IN (
+		SELECT min(rowid) FROM attachments GROUP BY message_id, name, content_id, hash);
+	DELETE FROM messages WHERE id != '' AND rowid NOT IN (SELECT min(rowid) FROM messages GROUP BY id);
+	CREATE UNIQUE INDEX messages_id ON messages (id) WHERE id != '';
+	CREATE UNIQUE INDEX attachments_key ON attachments (message_id, name, content_id, hash) WHERE message_id != '';
+	CREATE INDEX messages_chat_address ON messages (chat_address, sent_date);
+	CREATE INDEX messages_sent_date ON messages (sent_date);
+	`)
+	return err
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func userVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func indexes(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'messages' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMigrateNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mchat.db")
+	db, err := OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := userVersion(t, db); v != len(migrations) {
+		t.Errorf("version %d, want %d", v, len(migrations))
+	}
+	got := strings.Join(indexes(t, db), " ")
+	if got != "messages_chat_address messages_id messages_sent_date" {
+		t.Errorf("indexes %q", got)
+	}
+	db.Close()
+
+	// opening it again runs nothing
+	db, err = OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+}
+
+func TestMigrateFirstRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mchat.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE messages (id TEXT, from_addr TEXT, to_addr TEXT, contact TEXT, chat_address TEXT, content TEXT, sent_date DATETIME);
+	INSERT INTO messages VALUES ('1', 'anna@example.com', 'me@example.com', 'Anna', 'anna@example.com', 'hi', '2024-01-02 10:00:00');
+	INSERT INTO messages VALUES ('1', 'anna@example.com', 'me@example.com', 'Anna', 'anna@example.com', 'hi', '2024-01-02 10:00:00');
+	INSERT INTO messages VALUES ('2', 'me@example.com', 'anna@example.com', '', 'anna@example.com', 'hello', '2024-01-02 12:01:00 +0200 CEST m=+0.000123');
+	INSERT INTO messages VALUES ('', 'bob@example.org', 'me@example.com', 'Bob', 'bob@example.org', 'a', '2024-01-02 10:02:00');
+	INSERT INTO messages VALUES ('', 'bob@example.org', 'me@example.com', 'Bob', 'bob@example.org', 'b', '2024-01-02 10:03:00');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	msgs, err := GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("%d messages, want the copy of 1 dropped", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.Outgoing != (m.Id == "2") {
+			t.Errorf("message %q outgoing %v", m.Id, m.Outgoing)
+		}
+	}
+	if _, err := SaveMessage(db, msgs[0]); err != nil {
+		t.Errorf("saving a stored message again: %v", err)
+	}
+
+	var date string
+	if err := db.QueryRow(`SELECT CAST(sent_date AS TEXT) FROM messages WHERE id = '2'`).Scan(&date); err != nil {
+		t.Fatal(err)
+	}
+	if date != "2024-01-02 10:01:00+00:00" {
+		t.Errorf("sent_date %q, want it in UTC", date)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mchat.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 1000`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := OpenDB(path); err == nil {
+		t.Error("a database of a newer version should not be opened")
+	}
+}
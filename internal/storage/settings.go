@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"database/sql"
+	"mchat/internal/models"
+)
+
+// GetChatSettings returns the settings of the chats that have any, by
+// chat address
+func GetChatSettings(db *sql.DB) (map[string]*models.ChatSettings, error) {
+	rows, err := db.Query(`SELECT chat_address, encrypt FROM chat_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]*models.ChatSettings)
+	for rows.Next() {
+		var address string
+		var s models.ChatSettings
+		if err := rows.Scan(&address, &s.Encrypt); err != nil {
+			return nil, err
+		}
+		settings[address] = &s
+	}
+	return settings, rows.Err()
+}
+
+// GetChatSetting returns the settings of a chat, the defaults if it has none
+func GetChatSetting(db *sql.DB, chatAddress string) (*models.ChatSettings, error) {
+	var s models.ChatSettings
+	err := db.QueryRow(`SELECT encrypt FROM chat_settings WHERE chat_address = ?`, chatAddress).Scan(&s.Encrypt)
+	if err == sql.ErrNoRows {
+		return &s, nil
+	}
+	return &s, err
+}
+
+func SaveChatSettings(db *sql.DB, chatAddress string, s *models.ChatSettings) error {
+	_, err := db.Exec(
+		`INSERT INTO chat_settings (chat_address, encrypt) VALUES (?, ?)
+		ON CONFLICT (chat_address) DO UPDATE SET encrypt = excluded.encrypt`,
+		chatAddress, s.Encrypt,
+	)
+	return err
+}
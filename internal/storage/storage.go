@@ -5,6 +5,8 @@ import (
 	"mchat/internal/models"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
 	_ "modernc.org/sqlite"
@@ -17,20 +19,17 @@ func getPath() (string, error) {
 	return path, err
 }
 
-func initDb(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id TEXT,
-		from_addr TEXT,
-		to_addr TEXT,
-		contact TEXT,
-		chat_address TEXT,
-		content TEXT,
-		sent_date DATETIME
-    );
-	`
-	_, err := db.Exec(schema)
-	return err
+// messageColumns lists the messages columns in the order they are scanned
+const messageColumns = `id, from_addr, to_addr, contact, chat_address, content, sent_date,
+	message_id, in_reply_to, refs, subject, body, calendar, vcard, encrypted, signature, signer,
+	auth, auth_results, recipients, outgoing, reply_to`
+
+// dateFormat stores the dates in UTC so that their text sorts in time
+// order, the driver reads them back as time.Time
+const dateFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format(dateFormat)
 }
 
 func GetDB() (*sql.DB, error) {
@@ -38,17 +37,43 @@ func GetDB() (*sql.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	return OpenDB(path)
+}
 
+// OpenDB opens the database at path, creating or migrating its tables
+func OpenDB(path string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, err
 	}
-	err = initDb(db)
-	return db, err
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (*models.Message, error) {
+	var msg models.Message
+	var refs, recipients, replyTo string
+	err := row.Scan(&msg.Id, &msg.From, &msg.To, &msg.Contact, &msg.ChatAddress, &msg.Content, &msg.Date,
+		&msg.MessageId, &msg.InReplyTo, &refs, &msg.Subject, &msg.Body, &msg.Calendar, &msg.VCard,
+		&msg.Encrypted, &msg.Signature, &msg.Signer, &msg.Auth, &msg.AuthResults, &recipients, &msg.Outgoing, &replyTo)
+	if err != nil {
+		return nil, err
+	}
+	msg.References = strings.Fields(refs)
+	msg.Recipients = strings.Fields(recipients)
+	msg.ReplyTo = strings.Fields(replyTo)
+	return &msg, nil
 }
 
 func GetMessages(db *sql.DB) ([]*models.Message, error) {
-	rows, err := db.Query(`SELECT * FROM messages`)
+	rows, err := db.Query(`SELECT ` + messageColumns + ` FROM messages`)
 	if err != nil {
 		return nil, err
 	}
@@ -56,20 +81,108 @@ func GetMessages(db *sql.DB) ([]*models.Message, error) {
 	var msgs []*models.Message
 
 	for rows.Next() {
-		var msg models.Message
-		err = rows.Scan(&msg.Id, &msg.From, &msg.To, &msg.Contact, &msg.ChatAddress, &msg.Content, &msg.Date)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		msgs = append(msgs, &msg)
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	attachments, err := getAttachments(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		m.Attachments = attachments[m.Id]
 	}
 	return msgs, nil
 }
 
-func SaveMessage(db *sql.DB, msg *models.Message) error {
-	_, err := db.Exec(
-		`INSERT INTO messages (id, from_addr, to_addr, contact, chat_address, content, sent_date) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		msg.Id, msg.From, msg.To, msg.Contact, msg.ChatAddress, msg.Content, msg.Date,
+// getAttachments returns the attachments metadata grouped by message id
+func getAttachments(db *sql.DB) (map[string][]*models.Attachment, error) {
+	rows, err := db.Query(`SELECT message_id, name, content_type, content_id, size, hash FROM attachments ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make(map[string][]*models.Attachment)
+	for rows.Next() {
+		var msgId string
+		var a models.Attachment
+		err := rows.Scan(&msgId, &a.Name, &a.ContentType, &a.ContentId, &a.Size, &a.Hash)
+		if err != nil {
+			return nil, err
+		}
+		a.Path, err = BlobPath(a.Hash)
+		if err != nil {
+			return nil, err
+		}
+		attachments[msgId] = append(attachments[msgId], &a)
+	}
+	return attachments, rows.Err()
+}
+
+// SaveAttachments stores the content of the message attachments and records
+// their metadata, data holds the content of each attachment in order
+func SaveAttachments(db *sql.DB, msg *models.Message, data [][]byte) error {
+	for i, a := range msg.Attachments {
+		hash, err := SaveBlob(data[i])
+		if err != nil {
+			return err
+		}
+		a.Hash = hash
+		a.Path, err = BlobPath(hash)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(
+			// the attachments of a message saved again are already recorded
+			`INSERT OR IGNORE INTO attachments (message_id, name, content_type, content_id, size, hash) VALUES (?, ?, ?, ?, ?, ?)`,
+			msg.Id, a.Name, a.ContentType, a.ContentId, a.Size, a.Hash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLastThreadMessage returns the most recent message of the chat that can
+// be replied to, or nil if there is none
+func GetLastThreadMessage(db *sql.DB, chatAddress string) (*models.Message, error) {
+	row := db.QueryRow(`SELECT `+messageColumns+` FROM messages
+		WHERE chat_address = ? AND message_id != ''
+		ORDER BY sent_date DESC LIMIT 1`, chatAddress)
+	msg, err := scanMessage(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return msg, err
+}
+
+// SaveMessage stores a message, it reports false for a message already
+// stored, as one fetched again
+func SaveMessage(db *sql.DB, msg *models.Message) (bool, error) {
+	res, err := db.Exec(
+		`INSERT OR IGNORE INTO messages (`+messageColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.Id, msg.From, msg.To, msg.Contact, msg.ChatAddress, msg.Content, formatDate(msg.Date),
+		msg.MessageId, msg.InReplyTo, strings.Join(msg.References, " "), msg.Subject, msg.Body,
+		msg.Calendar, msg.VCard, msg.Encrypted, msg.Signature, msg.Signer, msg.Auth, msg.AuthResults,
+		strings.Join(msg.Recipients, " "), msg.Outgoing, strings.Join(msg.ReplyTo, " "),
 	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateDirection saves the direction and the chat of a message
+func UpdateDirection(db *sql.DB, msg *models.Message) error {
+	_, err := db.Exec(`UPDATE messages SET outgoing = ?, chat_address = ? WHERE id = ?`, msg.Outgoing, msg.ChatAddress, msg.Id)
 	return err
 }
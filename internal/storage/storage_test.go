@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"mchat/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+func TestGetLastThreadMessage(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "mchat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the later message has the earlier local time
+	tokyo := time.FixedZone("JST", 9*60*60)
+	berlin := time.FixedZone("CEST", 2*60*60)
+	msgs := []*models.Message{
+		{Id: "1", ChatAddress: "anna@example.com", MessageId: "<1@example.com>", Date: time.Date(2024, 5, 1, 18, 0, 0, 0, tokyo)},
+		{Id: "2", ChatAddress: "anna@example.com", MessageId: "<2@example.com>", Date: time.Date(2024, 5, 1, 11, 30, 0, 0, berlin)},
+		{Id: "3", ChatAddress: "anna@example.com", Date: time.Date(2024, 5, 1, 12, 0, 0, 0, berlin)},
+		{Id: "4", ChatAddress: "bob@example.org", MessageId: "<4@example.org>", Date: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, m := range msgs {
+		if _, err := SaveMessage(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, err := GetLastThreadMessage(db, "anna@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || last.Id != "2" {
+		t.Fatalf("last = %+v, want message 2", last)
+	}
+	if !last.Date.Equal(msgs[1].Date) {
+		t.Errorf("date %v, want %v", last.Date, msgs[1].Date)
+	}
+
+	if last, err := GetLastThreadMessage(db, "carol@example.net"); err != nil || last != nil {
+		t.Errorf("got %+v, %v for a chat without messages", last, err)
+	}
+}
+
+func TestSaveMessageAgain(t *testing.T) {
+	xdg.DataHome = t.TempDir()
+	db, err := OpenDB(filepath.Join(t.TempDir(), "mchat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := &models.Message{
+		Id: "1", ChatAddress: "anna@example.com",
+		Attachments: []*models.Attachment{{Name: "notes.txt", ContentType: "text/plain", Size: 5}},
+	}
+	data := [][]byte{[]byte("notes")}
+	for i, want := range []bool{true, false} {
+		inserted, err := SaveMessage(db, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted != want {
+			t.Errorf("save %d: inserted %v, want %v", i+1, inserted, want)
+		}
+		// the attachments of a message saved again aren't recorded twice
+		if err := SaveAttachments(db, m, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs, err := GetMessages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("%d messages stored", len(msgs))
+	}
+	if n := len(msgs[0].Attachments); n != 1 {
+		t.Errorf("%d attachments recorded", n)
+	}
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"mchat/internal/models"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mergeResult reports the outcome of merging the chat of alias into the one
+// of into
+type mergeResult struct {
+	alias, into string
+	err         error
+}
+
+// mergeChat merges the open chat into the chat of another address of the
+// same person
+func (m model) mergeChat(into string) (model, tea.Cmd) {
+	alias := m.chats.chats[m.chats.contactsList.Index()].Address
+	return m, func() tea.Msg {
+		// the chat of an address merged before is the one merged into
+		into, err := m.svc.MergeChat(alias, into)
+		return mergeResult{alias: alias, into: into, err: err}
+	}
+}
+
+// updateMerge moves the messages of the merged chat to the chat it was
+// merged into, which takes its place when there was none
+func (m model) updateMerge(msg mergeResult) model {
+	if msg.err != nil {
+		m.chats.notice = errorNotice(msg.err)
+		return m
+	}
+	from := slices.IndexFunc(m.chats.chats, func(c *models.Chat) bool { return c.Address == msg.alias })
+	if from < 0 {
+		return m
+	}
+	merged := m.chats.chats[from]
+	if draft, ok := m.chats.drafts[msg.alias]; ok {
+		m.chats.drafts[msg.into] = append(m.chats.drafts[msg.into], draft...)
+		delete(m.chats.drafts, msg.alias)
+	}
+	// as in the database, the settings of the chat merged into win
+	if settings, ok := m.chats.settings[msg.alias]; ok {
+		if _, ok := m.chats.settings[msg.into]; !ok {
+			m.chats.settings[msg.into] = settings
+		}
+		delete(m.chats.settings, msg.alias)
+	}
+	into := slices.IndexFunc(m.chats.chats, func(c *models.Chat) bool { return c.Address == msg.into })
+	if into < 0 {
+		merged.Address = msg.into
+		for _, message := range merged.Messages {
+			message.ChatAddress = msg.into
+		}
+		m.chats.notice = "Merged into " + msg.into
+		m = m.refreshChatItems()
+		return m.updateMessages(merged)
+	}
+
+	target := m.chats.chats[into]
+	for _, message := range merged.Messages {
+		message.ChatAddress = target.Address
+		target.Messages = appendIfNew(target.Messages, message)
+	}
+	slices.SortStableFunc(target.Messages, func(a, b *models.Message) int { return a.Date.Compare(b.Date) })
+
+	m.chats.chats = slices.Delete(m.chats.chats, from, from+1)
+	items := slices.Delete(m.chats.contactsList.Items(), from, from+1)
+	if into > from {
+		into--
+	}
+	// the chat merged into is the open one
+	if item, ok := items[into].(contactItem); ok {
+		item.selected = m.focus != focusChats
+		items[into] = item
+	}
+	m.chats.contactsList.SetItems(items)
+	m.chats.contactsList.Select(into)
+	m = m.refreshChatItems()
+	m.chats.notice = "Merged into " + target.Address
+	return m.updateMessages(target)
+}
@@ -0,0 +1,26 @@
+package ui
+
+import (
+	"mchat/internal/models"
+	"testing"
+)
+
+func TestUpdateMerge(t *testing.T) {
+	m := InitialModel(stubService{})
+	work := &models.Chat{Address: "anna@work.example", Messages: []*models.Message{{Id: "1", ChatAddress: "anna@work.example"}}}
+	home := &models.Chat{Address: "anna@example.com", Messages: []*models.Message{{Id: "2", ChatAddress: "anna@example.com"}}}
+	m = m.addChat(work)
+	m = m.addChat(home)
+	m.chats.settings[work.Address] = &models.ChatSettings{Encrypt: true}
+
+	m = m.updateMerge(mergeResult{alias: work.Address, into: home.Address})
+	if len(m.chats.chats) != 1 || len(home.Messages) != 2 {
+		t.Fatalf("%d chats, %d messages in the one merged into", len(m.chats.chats), len(home.Messages))
+	}
+	if s := m.chats.settings[home.Address]; s == nil || !s.Encrypt {
+		t.Error("the settings of the merged chat were lost")
+	}
+	if _, ok := m.chats.settings[work.Address]; ok {
+		t.Error("the settings stayed under the merged address")
+	}
+}
@@ -2,6 +2,8 @@ package ui
 
 import (
 	"mchat/internal/models"
+	"mchat/pkg/mlist"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -12,6 +14,19 @@ type DataService interface {
 	SaveBasicConfig(user, pass string)
 	SaveGoogleConfig(user string, token *oauth2.Token)
 	SendMessage(m *models.Message) error
+	SendDelay() time.Duration
+	PrepareAttachment(path string, queued []*models.Attachment) (*models.Attachment, error)
+	SaveAttachment(a *models.Attachment, dir string) (string, error)
+	OpenAttachment(a *models.Attachment) error
+	EventReply(invitation *models.Message, status string) (*models.Message, error)
+	GetContacts() ([]*models.Contact, error)
+	SaveContact(c *models.Contact) error
+	ChatSettings() (map[string]*models.ChatSettings, error)
+	SetEncrypt(chatAddress string, encrypt bool) error
+	Lists() (map[string]*mlist.List, error)
+	Unsubscribe(chatAddress string) (string, error)
+	MergeChat(alias, into string) (string, error)
+	NormalizeAddress(address string) string
 }
 
 var (
@@ -54,6 +69,7 @@ const (
 	focusChats focus = iota
 	focusChat
 	focusMessageInput
+	focusPrompt
 )
 
 type model struct {
@@ -80,7 +96,7 @@ func InitialModel(svc DataService) model {
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.loadContacts, m.loadChatSettings, m.loadLists)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -88,8 +104,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 	if msg, ok := msg.(*models.Message); ok {
+		if id, ok := models.ChannelId(msg.ChatAddress); ok && m.chats.lists[id] == nil {
+			// a new channel, its name comes from the list headers
+			return m.newMessage(msg), m.loadLists
+		}
 		return m.newMessage(msg), nil
 	}
+	if _, ok := msg.(pendingTick); ok {
+		return m.sendPending()
+	}
+	switch msg.(type) {
+	case contactsResult, contactResult:
+		return m.updateContacts(msg), nil
+	case chatSettingsResult, encryptResult:
+		return m.updateChatSettings(msg), nil
+	case listsResult, unsubscribeResult:
+		return m.updateLists(msg), nil
+	}
+	if msg, ok := msg.(mergeResult); ok {
+		return m.updateMerge(msg), nil
+	}
 	if msg, ok := msg.(tea.WindowSizeMsg); ok {
 		m.width = msg.Width
 		m.height = msg.Height
@@ -0,0 +1,207 @@
+package ui
+
+import (
+	"fmt"
+	"mchat/internal/models"
+	"strings"
+
+	"github.com/adrg/xdg"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
+)
+
+var (
+	chipStyle         = lipgloss.NewStyle().Foreground(colPrimary)
+	chipSelectedStyle = chipStyle.Reverse(true)
+	noticeStyle       = lipgloss.NewStyle().Foreground(colMuted).PaddingLeft(1)
+)
+
+// promptKind tells what the one-line prompt under the chat is asking for
+type promptKind int
+
+const (
+	promptAttach promptKind = iota
+	promptSaveDir
+	promptMerge
+	promptAnswer
+	promptUnsubscribe
+)
+
+// attachmentResult reports the outcome of saving or opening an attachment
+type attachmentResult struct {
+	notice string
+	err    error
+}
+
+func attachmentChip(a *models.Attachment) string {
+	return fmt.Sprintf("📎 %s · %s", a.Name, humanize.Bytes(uint64(a.Size)))
+}
+
+// attachmentChips renders the attachments one per line, selected is the index
+// of the highlighted one or -1
+func attachmentChips(attachments []*models.Attachment, selected int) string {
+	chips := make([]string, len(attachments))
+	for i, a := range attachments {
+		if i == selected {
+			chips[i] = chipSelectedStyle.Render(attachmentChip(a))
+		} else {
+			chips[i] = chipStyle.Render(attachmentChip(a))
+		}
+	}
+	return strings.Join(chips, "\n")
+}
+
+// draft returns the files queued on the message composed in the open chat
+func (m model) draft() []*models.Attachment {
+	if len(m.chats.chats) == 0 {
+		return nil
+	}
+	return m.chats.drafts[m.chats.chats[m.chats.contactsList.Index()].Address]
+}
+
+// setDraft replaces the files queued on the message composed in the open chat
+func (m model) setDraft(files []*models.Attachment) model {
+	if len(m.chats.chats) == 0 {
+		return m
+	}
+	address := m.chats.chats[m.chats.contactsList.Index()].Address
+	if len(files) == 0 {
+		delete(m.chats.drafts, address)
+	} else {
+		m.chats.drafts[address] = files
+	}
+	return m
+}
+
+// viewDraft renders the files queued on the draft and the last notice
+func (m model) viewDraft() string {
+	var line string
+	if draft := m.draft(); len(draft) > 0 {
+		chips := make([]string, len(draft))
+		for i, a := range draft {
+			chips[i] = chipStyle.Render(attachmentChip(a))
+		}
+		line = strings.Join(chips, "  ")
+	}
+	if m.chats.notice != "" {
+		line += " " + m.chats.notice
+	}
+	return noticeStyle.MaxWidth(m.chats.messagesViewport.Width).Render(line)
+}
+
+func errorNotice(err error) string {
+	return lipgloss.NewStyle().Foreground(colDanger).Render(err.Error())
+}
+
+func (m model) selectedAttachment() *models.Attachment {
+	msg := m.chats.selected
+	if msg == nil || len(msg.Attachments) == 0 {
+		return nil
+	}
+	return msg.Attachments[m.chats.selectedAttachment%len(msg.Attachments)]
+}
+
+func (m model) openPrompt(kind promptKind) model {
+	m.chats.prompt = kind
+	m.chats.notice = ""
+	switch kind {
+	case promptAttach:
+		m.chats.textInput.Blur()
+		m.chats.promptInput.Prompt = "Attach file: "
+		m.chats.promptInput.Placeholder = "path to the file"
+	case promptSaveDir:
+		m.chats.promptInput.Prompt = "Save to: "
+		m.chats.promptInput.Placeholder = "directory"
+		m.chats.promptInput.SetValue(xdg.UserDirs.Download)
+		m.chats.promptInput.CursorEnd()
+	case promptMerge:
+		m.chats.promptInput.Prompt = "Merge into: "
+		m.chats.promptInput.Placeholder = "other address of the person"
+	case promptAnswer:
+		m.chats.promptInput.Prompt = "Answer: "
+		m.chats.promptInput.Placeholder = "accept, maybe or decline"
+	case promptUnsubscribe:
+		id, _ := models.ChannelId(m.chats.chats[m.chats.contactsList.Index()].Address)
+		m.chats.promptInput.Prompt = "Unsubscribe from " + m.channelName(id) + "? "
+		m.chats.promptInput.Placeholder = "yes or no"
+	}
+	m.chats.promptInput.Width = m.chats.textInput.Width() - len(m.chats.promptInput.Prompt)
+	m.chats.promptInput.Focus()
+	m.focus = focusPrompt
+	return m
+}
+
+func (m model) updatePrompt(msg tea.KeyMsg) (model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "esc", "shift+tab":
+		m = m.leavePrompt()
+		return m, nil
+	case "enter":
+		value := strings.TrimSpace(m.chats.promptInput.Value())
+		if value == "" {
+			m = m.leavePrompt()
+			return m, nil
+		}
+		switch m.chats.prompt {
+		case promptAttach:
+			a, err := m.svc.PrepareAttachment(value, m.draft())
+			if err != nil {
+				m.chats.notice = errorNotice(err)
+				return m, nil
+			}
+			m = m.setDraft(append(m.draft(), a))
+			m = m.leavePrompt()
+			return m, nil
+		case promptSaveDir:
+			a := m.selectedAttachment()
+			m = m.leavePrompt()
+			if a == nil {
+				return m, nil
+			}
+			return m, func() tea.Msg {
+				path, err := m.svc.SaveAttachment(a, value)
+				return attachmentResult{notice: "saved " + path, err: err}
+			}
+		case promptMerge:
+			m = m.leavePrompt()
+			return m.mergeChat(value)
+		case promptAnswer:
+			m = m.leavePrompt()
+			return m.respondToEvent(value)
+		case promptUnsubscribe:
+			m = m.leavePrompt()
+			if !strings.HasPrefix(strings.ToLower(value), "y") {
+				return m, nil
+			}
+			return m.unsubscribe()
+		}
+	}
+	m.chats.promptInput, cmd = m.chats.promptInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) leavePrompt() model {
+	m.chats.promptInput.SetValue("")
+	m.chats.promptInput.Blur()
+	m.chats.notice = ""
+	if m.chats.prompt == promptAttach {
+		m.chats.textInput.Focus()
+		m.focus = focusMessageInput
+	} else {
+		m.focus = focusChat
+	}
+	return m
+}
+
+func (m model) openAttachment() tea.Cmd {
+	a := m.selectedAttachment()
+	if a == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		err := m.svc.OpenAttachment(a)
+		return attachmentResult{notice: "opened " + a.Name, err: err}
+	}
+}
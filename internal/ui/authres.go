@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"mchat/internal/models"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// usualNames returns the display name each sender of the chat most often
+// sends with, by lowercased address; messages failing the sender checks
+// don't count
+func usualNames(chat *models.Chat) map[string]string {
+	counts := make(map[string]map[string]int)
+	usual := make(map[string]string)
+	for _, msg := range chat.Messages {
+		name := strings.TrimSpace(msg.Contact)
+		if msg.Outgoing || msg.Auth == models.AuthFail || name == "" {
+			continue
+		}
+		from := strings.ToLower(msg.From)
+		if counts[from] == nil {
+			counts[from] = make(map[string]int)
+		}
+		counts[from][name]++
+		// ties go to the earliest name
+		if counts[from][name] > counts[from][usual[from]] {
+			usual[from] = name
+		}
+	}
+	return usual
+}
+
+// authBadge warns about a received message whose sender may be forged: it
+// failed DKIM or DMARC, or its display name isn't the usual one of the
+// sender, usual holds them by address
+func authBadge(msg *models.Message, usual map[string]string) string {
+	if msg.Outgoing {
+		return ""
+	}
+	if msg.Auth == models.AuthFail {
+		return lipgloss.NewStyle().Foreground(colDanger).Render("⚠ forged sender? " + msg.AuthResults)
+	}
+	name := strings.TrimSpace(msg.Contact)
+	u := usual[strings.ToLower(msg.From)]
+	if name != "" && u != "" && !strings.EqualFold(name, u) {
+		return lipgloss.NewStyle().Foreground(colWarning).Render("⚠ usually " + u)
+	}
+	return ""
+}
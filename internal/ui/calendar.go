@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"mchat/internal/models"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	eventTitleStyle     = lipgloss.NewStyle().Foreground(colPrimary).Bold(true)
+	eventCancelledStyle = lipgloss.NewStyle().Foreground(colMuted).Strikethrough(true)
+	eventMutedStyle     = lipgloss.NewStyle().Foreground(colMuted)
+)
+
+// maxCardAttendees is the number of attendees listed on an event card
+const maxCardAttendees = 6
+
+var partStatSymbols = map[string]string{
+	"ACCEPTED":  "✓",
+	"TENTATIVE": "?",
+	"DECLINED":  "✗",
+}
+
+var partStatVerbs = map[string]string{
+	"ACCEPTED":  "accepted",
+	"TENTATIVE": "tentatively accepted",
+	"DECLINED":  "declined",
+}
+
+// isInvitation tells whether the message asks the user to answer an event
+func isInvitation(msg *models.Message) bool {
+	e := msg.Event
+	return e != nil && e.Method == "REQUEST" && !e.Cancelled && !msg.Outgoing
+}
+
+func attendeeName(a *models.Attendee) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Address
+}
+
+// eventCard renders the event of an invitation or of a reply to it,
+// actions adds the keys answering an invitation
+func eventCard(e *models.Event, actions bool) string {
+	title := "📅 " + e.Summary
+	if e.Summary == "" {
+		title = "📅 (no title)"
+	}
+	var lines []string
+	if e.Cancelled {
+		lines = append(lines, eventCancelledStyle.Render(title), eventMutedStyle.Render("Cancelled"))
+	} else {
+		lines = append(lines, eventTitleStyle.Render(title))
+	}
+	lines = append(lines, "🕓 "+eventTime(e))
+
+	if e.Method == "REPLY" {
+		for _, a := range e.Attendees {
+			verb, ok := partStatVerbs[a.Status]
+			if !ok {
+				verb = "replied"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", attendeeName(a), verb))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if e.Location != "" {
+		lines = append(lines, "📍 "+e.Location)
+	}
+	if e.Organizer != nil {
+		lines = append(lines, "👤 "+attendeeName(e.Organizer)+eventMutedStyle.Render(" (organizer)"))
+	}
+	if len(e.Attendees) > 0 {
+		var names []string
+		for i, a := range e.Attendees {
+			if i == maxCardAttendees {
+				names = append(names, fmt.Sprintf("+%d more", len(e.Attendees)-i))
+				break
+			}
+			name := attendeeName(a)
+			if s, ok := partStatSymbols[a.Status]; ok {
+				name += " " + s
+			}
+			names = append(names, name)
+		}
+		lines = append(lines, "👥 "+strings.Join(names, ", "))
+	}
+	if actions {
+		lines = append(lines, eventMutedStyle.Render("A to answer"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// eventTime formats the event time span in the local time zone
+func eventTime(e *models.Event) string {
+	start, end := e.Start.Local(), e.End.Local()
+	if e.AllDay {
+		// the end date of all-day events is exclusive
+		end = e.End.AddDate(0, 0, -1)
+		if !end.After(e.Start) {
+			return e.Start.Format("Mon 2 Jan 2006") + ", all day"
+		}
+		return e.Start.Format("Mon 2 Jan") + " – " + end.Format("Mon 2 Jan 2006") + ", all day"
+	}
+	s := start.Format("Mon 2 Jan 2006, 15:04")
+	if end.Equal(start) {
+		return s
+	}
+	if start.Format("20060102") == end.Format("20060102") {
+		return s + "–" + end.Format("15:04")
+	}
+	return s + " – " + end.Format("Mon 2 Jan 2006, 15:04")
+}
+
+// partStatAnswers are the answers typed in the prompt, by their first letter
+var partStatAnswers = map[byte]string{
+	'a': "ACCEPTED",
+	'y': "ACCEPTED",
+	't': "TENTATIVE",
+	'm': "TENTATIVE",
+	'd': "DECLINED",
+	'n': "DECLINED",
+}
+
+// answerInvitation asks how to answer the selected invitation
+func (m model) answerInvitation() model {
+	if msg := m.chats.selected; msg == nil || !isInvitation(msg) {
+		return m
+	}
+	return m.openPrompt(promptAnswer)
+}
+
+// respondToEvent answers the selected invitation as typed in the prompt,
+// the reply goes through the undo-send grace period like any message
+func (m model) respondToEvent(answer string) (model, tea.Cmd) {
+	msg := m.chats.selected
+	if msg == nil || !isInvitation(msg) {
+		return m, nil
+	}
+	status, ok := partStatAnswers[strings.ToLower(answer)[0]]
+	if !ok {
+		m.chats.notice = errorNotice(fmt.Errorf("answer accept, maybe or decline, not %q", answer))
+		return m, nil
+	}
+	reply, err := m.svc.EventReply(msg, status)
+	if err != nil {
+		m.chats.notice = errorNotice(err)
+		return m, nil
+	}
+	verb := partStatVerbs[status]
+	reply.Content = strings.ToUpper(verb[:1]) + verb[1:] + ": " + msg.Event.Summary
+	m.chats.notice = ""
+	return m.queueMessage(reply)
+}
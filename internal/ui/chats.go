@@ -1,12 +1,17 @@
 package ui
 
 import (
+	"fmt"
 	"log"
 	"mchat/internal/models"
+	"mchat/pkg/mlist"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,12 +42,43 @@ type sendMessageResult struct {
 	err error
 }
 
+const maxComposerHeight = 6
+
+// pendingTick drives the undo-send countdown
+type pendingTick struct{}
+
 type chatsModel struct {
 	chats []*models.Chat
 
+	// outgoing messages waiting for the undo-send grace period to pass
+	pending map[*models.Message]time.Time
+	ticking bool
+
 	contactsList     list.Model
 	messagesViewport viewport.Model
-	textInput        textinput.Model
+	textInput        textarea.Model
+	promptInput      textinput.Model
+	prompt           promptKind
+
+	// selected message of the open chat and its selected attachment
+	selected           *models.Message
+	selectedAttachment int
+	// index of the next code block of the selected message to copy
+	selectedCode int
+	// ids of the messages showing their quoted history and signature
+	expanded map[string]bool
+	// position of the selected message in the viewport content
+	selectedLine, selectedHeight int
+
+	// settings of the chats by address
+	settings map[string]*models.ChatSettings
+	// mailing lists of the channels by List-Id
+	lists map[string]*mlist.List
+
+	// files queued on the message composed in each chat, by chat address
+	drafts map[string][]*models.Attachment
+	// notice is a one-line feedback shown under the composer
+	notice string
 }
 
 var (
@@ -52,8 +88,11 @@ var (
 	inputStyle = lipgloss.NewStyle().
 			BorderForeground(colPrimary).
 			Padding(1)
-	inMsgStyle  = getInMsgStyle()
-	outMsgStyle = getOutMsgStyle()
+	inMsgStyle   = getInMsgStyle()
+	outMsgStyle  = getOutMsgStyle()
+	subjectStyle = lipgloss.NewStyle().Foreground(colMuted).Italic(true).PaddingTop(1)
+	hiddenStyle  = lipgloss.NewStyle().Foreground(colMuted)
+	senderStyle  = lipgloss.NewStyle().Foreground(colPrimary).Bold(true)
 )
 
 var chatFocusedStyle = chatStyle.
@@ -105,13 +144,25 @@ func initChatsModel() chatsModel {
 	messages := viewport.New(40, 40)
 	messages.SetContent("Select a chat")
 
-	input := textinput.New()
-	input.Placeholder = "Send a message..."
+	input := textarea.New()
+	input.Placeholder = "Send a message... (Markdown, alt+enter for a new line)"
+	input.ShowLineNumbers = false
+	input.CharLimit = 0
+	input.MaxHeight = maxComposerHeight
+	input.SetHeight(1)
+	input.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
 
 	return chatsModel{
+		pending:          make(map[*models.Message]time.Time),
+		expanded:         make(map[string]bool),
+		settings:         make(map[string]*models.ChatSettings),
+		lists:            make(map[string]*mlist.List),
+		drafts:           make(map[string][]*models.Attachment),
 		contactsList:     contacts,
 		messagesViewport: messages,
 		textInput:        input,
+		promptInput:      textinput.New(),
 	}
 }
 
@@ -138,12 +189,15 @@ func (m model) viewChat() string {
 	view = lipgloss.JoinVertical(lipgloss.Left, view, m.viewScroll())
 
 	var input string
-	if m.focus == focusMessageInput {
+	switch m.focus {
+	case focusMessageInput:
 		input = inputStyle.Border(lipgloss.RoundedBorder()).Padding(0).Render(m.chats.textInput.View())
-	} else {
+	case focusPrompt:
+		input = inputStyle.Border(lipgloss.RoundedBorder()).Padding(0).Render(m.chats.promptInput.View())
+	default:
 		input = inputStyle.Render(m.chats.textInput.View())
 	}
-	view = lipgloss.JoinVertical(lipgloss.Left, view, input)
+	view = lipgloss.JoinVertical(lipgloss.Left, view, m.viewDraft(), input)
 	return view
 }
 
@@ -156,11 +210,20 @@ func (m model) viewChats() string {
 	return content
 }
 
-func messageStatusBar(m *models.Message) string {
-	dateText := m.Date.Format("Mon, 15:04")
+func (m model) messageStatusBar(msg *models.Message, usual map[string]string) string {
+	dateText := msg.Date.Format("Mon, 15:04")
 	bar := lipgloss.NewStyle().Foreground(colMuted).Render(dateText)
-	if m.ChatAddress != m.From {
-		switch m.Status {
+	if badge := securityBadge(msg); badge != "" {
+		bar += " " + badge
+	}
+	if badge := authBadge(msg, usual); badge != "" {
+		bar += " " + badge
+	}
+	if msg.Outgoing {
+		switch msg.Status {
+		case models.MsgStatusQueued:
+			left := time.Until(m.chats.pending[msg]).Round(time.Second)
+			bar += " " + lipgloss.NewStyle().Foreground(colWarning).Render(fmt.Sprintf("⏲ %s, z to undo ", left))
 		case models.MsgStatusSuccess:
 			bar += " " + lipgloss.NewStyle().Foreground(colSuccess).Render("✓ ")
 		case models.MsgStatusSending:
@@ -172,20 +235,123 @@ func messageStatusBar(m *models.Message) string {
 	return bar
 }
 
+// hiddenText returns the quoted history and signature stripped from the
+// message content
+func hiddenText(msg *models.Message) string {
+	if msg.Event != nil {
+		// the event card replaces the text of invitations
+		if body := strings.TrimSpace(msg.Body); body != "" {
+			return body
+		}
+		return strings.TrimSpace(msg.Content)
+	}
+	body := strings.TrimSpace(msg.Body)
+	content := strings.TrimSpace(msg.Content)
+	if body == "" || body == content {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(body, content); ok {
+		return strings.TrimSpace(rest)
+	}
+	return body
+}
+
+// threadSubject strips the reply and forward prefixes added by mail clients
+func threadSubject(s string) string {
+	s = strings.TrimSpace(s)
+	for {
+		i := strings.Index(s, ":")
+		if i < 0 || !slices.Contains(subjectPrefixes, strings.ToLower(strings.TrimSpace(s[:i]))) {
+			return s
+		}
+		s = strings.TrimSpace(s[i+1:])
+	}
+}
+
+var subjectPrefixes = []string{"re", "fw", "fwd", "aw", "wg", "odp", "pd", "sv", "vs", "tr", "rif", "antw"}
+
+func (m model) viewSubject(msg *models.Message) string {
+	width := m.chats.messagesViewport.Width/10*9 - 2
+	header := subjectStyle.MaxWidth(width).Render("✉ " + msg.Subject)
+	if msg.Outgoing {
+		return lipgloss.NewStyle().Width(m.chats.messagesViewport.Width - 2).Align(lipgloss.Right).Render(header)
+	}
+	return header
+}
+
 func (m model) updateMessages(chat *models.Chat) model {
 	content := ""
+	subject := ""
+	m.chats.selectedLine, m.chats.selectedHeight = 0, 0
+	usual := usualNames(chat)
 	for _, msg := range chat.Messages {
-		text := msg.Content
+		if s := threadSubject(msg.Subject); s != "" && s != subject {
+			subject = s
+			content = lipgloss.JoinVertical(lipgloss.Left, content, m.viewSubject(msg))
+		}
+
+		maxWidth := m.chats.messagesViewport.Width / 10 * 9
+		isSelected := m.focus != focusChats && msg == m.chats.selected
+		var text string
+		if msg.Event != nil {
+			text = eventCard(msg.Event, isSelected && isInvitation(msg))
+		} else {
+			text = renderContent(msg.Content, msg.Outgoing, maxWidth-2)
+		}
+		if msg.Card != nil {
+			card := contactCard(msg.Card, isSelected)
+			if strings.TrimSpace(text) == "" {
+				text = card
+			} else {
+				text = lipgloss.JoinVertical(lipgloss.Left, text, card)
+			}
+		}
+		if hidden := hiddenText(msg); hidden != "" {
+			if m.chats.expanded[msg.Id] {
+				text = lipgloss.JoinVertical(lipgloss.Left, text, "", hiddenStyle.Render(hidden))
+			} else {
+				text = lipgloss.JoinVertical(lipgloss.Left, text, hiddenStyle.Render("⋯"))
+			}
+		}
+		if (models.IsGroup(chat.Address) || models.IsChannel(chat.Address)) && !msg.Outgoing {
+			text = lipgloss.JoinVertical(lipgloss.Left, senderStyle.Render(senderName(msg)), text)
+		}
 		var msgBubble string
-		msgWidth := min(lipgloss.Width(text)+2, m.chats.messagesViewport.Width/10*9)
+		msgWidth := min(lipgloss.Width(text)+2, maxWidth)
 
-		if msg.ChatAddress != msg.From {
-			msgBubble = outMsgStyle.Width(msgWidth).Render(text)
-			msgBubble = lipgloss.JoinVertical(lipgloss.Right, msgBubble, messageStatusBar(msg))
+		if len(msg.Attachments) > 0 {
+			selectedChip := -1
+			if isSelected {
+				selectedChip = m.chats.selectedAttachment % len(msg.Attachments)
+			}
+			chips := attachmentChips(msg.Attachments, selectedChip)
+			msgWidth = min(max(msgWidth, lipgloss.Width(chips)+2), maxWidth)
+			if text == "" {
+				text = chips
+			} else {
+				text = lipgloss.JoinVertical(lipgloss.Left, text, chips)
+			}
+		}
+
+		if msg.Outgoing {
+			style := outMsgStyle
+			if isSelected {
+				style = style.BorderForeground(colPrimary)
+			}
+			msgBubble = style.Width(msgWidth).Render(text)
+			msgBubble = lipgloss.JoinVertical(lipgloss.Right, msgBubble, m.messageStatusBar(msg, usual))
 			msgBubble = lipgloss.NewStyle().Width(m.chats.messagesViewport.Width - 2).Align(lipgloss.Right).Render(msgBubble)
 		} else {
-			msgBubble = inMsgStyle.Width(msgWidth).Render(text)
-			msgBubble = lipgloss.JoinVertical(lipgloss.Left, msgBubble, messageStatusBar(msg))
+			style := inMsgStyle
+			if isSelected {
+				style = style.BorderForeground(colPrimary)
+			}
+			msgBubble = style.Width(msgWidth).Render(text)
+			msgBubble = lipgloss.JoinVertical(lipgloss.Left, msgBubble, m.messageStatusBar(msg, usual))
+		}
+		if isSelected {
+			m.chats.selectedLine = lipgloss.Height(content)
+			m.chats.selectedHeight = lipgloss.Height(msgBubble)
 		}
 		content = lipgloss.JoinVertical(lipgloss.Left, content, msgBubble)
 	}
@@ -202,10 +368,10 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 		w := msg.Width
 		m.chats.contactsList.SetWidth(w / 4)
 		m.chats.messagesViewport.Width = w - w/4
-		m.chats.textInput.Width = w - w/4 - 5
+		m.chats.textInput.SetWidth(w - w/4 - 5)
 
 		m.chats.contactsList.SetHeight(msg.Height - 10)
-		m.chats.messagesViewport.Height = msg.Height - 8
+		m = m.resizeComposer()
 
 		index := m.chats.contactsList.Index()
 		if len(m.chats.chats) > 0 {
@@ -214,6 +380,15 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return m, nil
 
+	case attachmentResult:
+		if msg.err != nil {
+			log.Println(msg.err)
+			m.chats.notice = errorNotice(msg.err)
+		} else {
+			m.chats.notice = msg.notice
+		}
+		return m, nil
+
 	case sendMessageResult:
 		if msg.err != nil {
 			log.Println("error while sending the message", msg.err)
@@ -253,6 +428,12 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.chats.contactsList.SetDelegate(listDelegate(true))
 					m.chats.contactsList.SetItems(items)
 
+					m.chats.selected = nil
+					if msgs := m.chats.chats[index].Messages; len(msgs) > 0 {
+						m.chats.selected = msgs[len(msgs)-1]
+					}
+					m.chats.selectedAttachment = 0
+					m.chats.selectedCode = 0
 					m = m.updateMessages(m.chats.chats[index])
 					m.chats.messagesViewport.GotoBottom()
 					return m, nil
@@ -280,7 +461,40 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.chats.contactsList.SetDelegate(listDelegate(false))
 				m.chats.contactsList.SetItems(items)
+				m = m.updateMessages(m.chats.chats[index])
 
+				return m, nil
+			case "K", "shift+up":
+				return m.selectMessage(-1), nil
+			case "J", "shift+down":
+				return m.selectMessage(1), nil
+			case "n":
+				m.chats.selectedAttachment++
+				return m.updateMessages(m.chats.chats[m.chats.contactsList.Index()]), nil
+			case "s":
+				if m.selectedAttachment() != nil {
+					return m.openPrompt(promptSaveDir), nil
+				}
+				return m, nil
+			case "o":
+				return m, m.openAttachment()
+			case "y":
+				return m.copyCode()
+			case "A":
+				return m.answerInvitation(), nil
+			case "i":
+				return m.importContact()
+			case "P":
+				return m.toggleEncrypt()
+			case "U":
+				return m.confirmUnsubscribe(), nil
+			case "M":
+				return m.openPrompt(promptMerge), nil
+			case "e":
+				if msg := m.chats.selected; msg != nil && hiddenText(msg) != "" {
+					m.chats.expanded[msg.Id] = !m.chats.expanded[msg.Id]
+					m = m.updateMessages(m.chats.chats[m.chats.contactsList.Index()])
+				}
 				return m, nil
 			case "g":
 				m.chats.messagesViewport.GotoTop()
@@ -291,6 +505,8 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "c":
 				m.view = viewConfig
 				return m, nil
+			case "z":
+				return m.undoSend(), nil
 			case "enter", "tab":
 				m.focus = focusMessageInput
 				m.chats.textInput.Focus()
@@ -305,40 +521,182 @@ func (m model) updateChats(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.chats.textInput.Blur()
 				m.focus = focusChat
 				return m, nil
+			case "ctrl+o":
+				return m.openPrompt(promptAttach), nil
+			case "ctrl+x":
+				if draft := m.draft(); len(draft) > 0 {
+					m = m.setDraft(draft[:len(draft)-1])
+				}
+				return m, nil
 			case "enter":
 				content := m.chats.textInput.Value()
 				content = strings.TrimSpace(content)
-				if content == "" {
+				if content == "" && len(m.draft()) == 0 {
 					return m, nil
 				}
 				index := m.chats.contactsList.Index()
 				msg := prepareMessage(m.chats.chats[index], content)
+				msg.Attachments = m.draft()
+				m = m.setDraft(nil)
+				m.chats.notice = ""
 
-				cmdSend := func() tea.Msg {
-					err := m.svc.SendMessage(msg)
-					return sendMessageResult{msg: msg, err: err}
-				}
-				m = m.newMessage(msg)
-				m.chats.messagesViewport.GotoBottom()
 				m.focus = focusChat
-				m.chats.textInput.SetValue("")
+				m.chats.textInput.Reset()
 				m.chats.textInput.Blur()
-
-				return m, cmdSend
+				m = m.resizeComposer()
+				return m.queueMessage(msg)
 			}
 			m.chats.textInput, cmd = m.chats.textInput.Update(msg)
+			m = m.resizeComposer()
 			return m, cmd
+
+		case focusPrompt:
+			return m.updatePrompt(msg)
 		}
 	}
 
 	return m, nil
 }
 
+// resizeComposer grows the composer with its content up to maxComposerHeight,
+// the chat viewport shrinks accordingly
+func (m model) resizeComposer() model {
+	h := min(max(m.chats.textInput.LineCount(), 1), maxComposerHeight)
+	m.chats.textInput.SetHeight(h)
+	m.chats.messagesViewport.Height = max(m.height-9-(h-1), 0)
+	return m
+}
+
+// selectMessage moves the message selection by delta and scrolls it into view
+func (m model) selectMessage(delta int) model {
+	chat := m.chats.chats[m.chats.contactsList.Index()]
+	if len(chat.Messages) == 0 {
+		return m
+	}
+	i := slices.Index(chat.Messages, m.chats.selected)
+	if i < 0 {
+		i = len(chat.Messages)
+	}
+	i = min(max(i+delta, 0), len(chat.Messages)-1)
+	m.chats.selected = chat.Messages[i]
+	m.chats.selectedAttachment = 0
+	m.chats.selectedCode = 0
+	m = m.updateMessages(chat)
+
+	v := &m.chats.messagesViewport
+	if m.chats.selectedLine < v.YOffset {
+		v.SetYOffset(m.chats.selectedLine)
+	} else if bottom := m.chats.selectedLine + m.chats.selectedHeight; bottom > v.YOffset+v.Height {
+		v.SetYOffset(bottom - v.Height)
+	}
+	return m
+}
+
+// queueMessage adds an outgoing message to its chat and sends it once the
+// undo-send grace period has passed
+func (m model) queueMessage(msg *models.Message) (model, tea.Cmd) {
+	delay := m.svc.SendDelay()
+	if delay > 0 {
+		msg.Status = models.MsgStatusQueued
+		m.chats.pending[msg] = msg.Date.Add(delay)
+	}
+	m = m.newMessage(msg)
+	m.chats.messagesViewport.GotoBottom()
+
+	if delay > 0 {
+		return m.tickPending()
+	}
+	return m, m.sendMessage(msg)
+}
+
+func (m model) sendMessage(msg *models.Message) tea.Cmd {
+	return func() tea.Msg {
+		err := m.svc.SendMessage(msg)
+		return sendMessageResult{msg: msg, err: err}
+	}
+}
+
+func (m model) tickPending() (model, tea.Cmd) {
+	if m.chats.ticking {
+		return m, nil
+	}
+	m.chats.ticking = true
+	return m, tea.Tick(time.Second, func(time.Time) tea.Msg { return pendingTick{} })
+}
+
+// sendPending hands over the queued messages whose grace period has passed
+func (m model) sendPending() (model, tea.Cmd) {
+	m.chats.ticking = false
+	var cmds []tea.Cmd
+	now := time.Now()
+	for msg, sendAt := range m.chats.pending {
+		if now.Before(sendAt) {
+			continue
+		}
+		delete(m.chats.pending, msg)
+		msg.Status = models.MsgStatusSending
+		cmds = append(cmds, m.sendMessage(msg))
+	}
+	if len(m.chats.pending) > 0 {
+		var cmd tea.Cmd
+		m, cmd = m.tickPending()
+		cmds = append(cmds, cmd)
+	}
+	if len(m.chats.chats) > 0 {
+		m = m.updateMessages(m.chats.chats[m.chats.contactsList.Index()])
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// undoSend cancels the latest queued message of the current chat
+// and puts its content back into the composer
+func (m model) undoSend() model {
+	if len(m.chats.chats) == 0 {
+		return m
+	}
+	chat := m.chats.chats[m.chats.contactsList.Index()]
+	for i := len(chat.Messages) - 1; i >= 0; i-- {
+		msg := chat.Messages[i]
+		if _, ok := m.chats.pending[msg]; !ok {
+			continue
+		}
+		delete(m.chats.pending, msg)
+		chat.Messages = append(chat.Messages[:i], chat.Messages[i+1:]...)
+		if m.chats.selected == msg {
+			m.chats.selected = nil
+		}
+		m = m.updateMessages(chat)
+
+		if msg.Event != nil {
+			// answers to invitations are not edited in the composer
+			m.chats.notice = "Reply cancelled"
+			return m
+		}
+		// what was composed since is kept after the cancelled message
+		content := msg.Content
+		if typed := strings.TrimSpace(m.chats.textInput.Value()); typed != "" {
+			content += "\n" + typed
+		}
+		m.chats.textInput.SetValue(content)
+		m = m.setDraft(append(msg.Attachments, m.draft()...))
+		m.chats.textInput.Focus()
+		m = m.resizeComposer()
+		m.focus = focusMessageInput
+		return m
+	}
+	return m
+}
+
 func (m model) newMessage(msg *models.Message) model {
 	index := m.chats.contactsList.Index()
 	for i, c := range m.chats.chats {
 		if c.Address == msg.ChatAddress {
 			c.Messages = appendIfNew(c.Messages, msg)
+			if models.IsGroup(c.Address) {
+				// the names of the participants are learnt from their messages
+				c.Name = groupName(c)
+				m.chats.contactsList.SetItem(i, m.chatItem(c))
+			}
 			if i == index {
 				m = m.updateMessages(c)
 				m.chats.messagesViewport.GotoBottom()
@@ -347,21 +705,81 @@ func (m model) newMessage(msg *models.Message) model {
 		}
 	}
 	c := models.Chat{Address: msg.ChatAddress, Name: msg.Contact, Messages: []*models.Message{msg}}
-	m.chats.chats = append(m.chats.chats, &c)
+	if models.IsGroup(c.Address) {
+		c.Name = groupName(&c)
+	}
+	return m.addChat(&c)
+}
+
+// addChat lists a new chat, the personal chats come before the channels of
+// the mailing lists
+func (m model) addChat(c *models.Chat) model {
+	i := len(m.chats.chats)
+	if !models.IsChannel(c.Address) {
+		i = slices.IndexFunc(m.chats.chats, func(c *models.Chat) bool { return models.IsChannel(c.Address) })
+		if i < 0 {
+			i = len(m.chats.chats)
+		}
+	}
+	selected := m.chats.contactsList.Index()
+	m.chats.chats = slices.Insert(m.chats.chats, i, c)
+	m.chats.contactsList.SetItems(slices.Insert(m.chats.contactsList.Items(), i, list.Item(m.chatItem(c))))
+	if len(m.chats.chats) > 1 && i <= selected {
+		// the open chat moves down
+		m.chats.contactsList.Select(selected + 1)
+	}
+	return m
+}
 
+// refreshChatItems renders the list items of the chats again
+func (m model) refreshChatItems() model {
 	items := m.chats.contactsList.Items()
-	items = append(items, contactItem{
-		title:       c.Name,
-		description: c.Address,
-	})
+	for i, c := range m.chats.chats {
+		if item, ok := items[i].(contactItem); ok {
+			selected := item.selected
+			item = m.chatItem(c)
+			item.selected = selected
+			items[i] = item
+		}
+	}
 	m.chats.contactsList.SetItems(items)
-
 	return m
 }
 
+// groupName lists the participants of a group chat
+func groupName(c *models.Chat) string {
+	var names []string
+	for _, p := range models.Participants(c.Address) {
+		names = append(names, participantName(c, p))
+	}
+	return "👥 " + strings.Join(names, ", ")
+}
+
+// participantName returns the latest display name a participant sent with,
+// or the local part of their address
+func participantName(c *models.Chat, address string) string {
+	for _, msg := range slices.Backward(c.Messages) {
+		if strings.EqualFold(msg.From, address) && msg.Contact != "" {
+			return msg.Contact
+		}
+	}
+	name, _, _ := strings.Cut(address, "@")
+	return name
+}
+
+// senderName names the sender of a message in a group chat
+func senderName(msg *models.Message) string {
+	if msg.Contact != "" {
+		return msg.Contact
+	}
+	return msg.From
+}
+
+// appendIfNew appends msg unless the chat holds it, the messages queued in
+// the composer have no id until they are sent
 func appendIfNew(msgs []*models.Message, msg *models.Message) []*models.Message {
 	for _, m := range msgs {
-		if m.Id == msg.Id {
+		if m == msg || msg.Id != "" && m.Id == msg.Id {
 			return msgs
 		}
 	}
@@ -376,5 +794,6 @@ func prepareMessage(c *models.Chat, s string) *models.Message {
 		Content:     s,
 		Date:        time.Now(),
 		Status:      models.MsgStatusSending,
+		Outgoing:    true,
 	}
 }
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"mchat/internal/models"
+	"testing"
+	"time"
+)
+
+// stubService answers the calls of the tests, the others panic
+type stubService struct {
+	DataService
+	delay time.Duration
+}
+
+func (s stubService) SendDelay() time.Duration { return s.delay }
+
+func TestUndoSendQueued(t *testing.T) {
+	m := InitialModel(stubService{delay: time.Minute})
+	chat := &models.Chat{Address: "anna@example.com", Name: "Anna"}
+	m = m.addChat(chat)
+
+	m, _ = m.queueMessage(prepareMessage(chat, "first"))
+	m, _ = m.queueMessage(prepareMessage(chat, "second"))
+	if len(chat.Messages) != 2 || len(m.chats.pending) != 2 {
+		t.Fatalf("%d messages shown, %d pending, want 2 of both", len(chat.Messages), len(m.chats.pending))
+	}
+
+	m = m.undoSend()
+	if len(chat.Messages) != 1 || chat.Messages[0].Content != "first" {
+		t.Fatalf("messages left %v, want the first one", chat.Messages)
+	}
+	if _, ok := m.chats.pending[chat.Messages[0]]; !ok || len(m.chats.pending) != 1 {
+		t.Errorf("pending %v, want the first message only", m.chats.pending)
+	}
+	if got := m.chats.textInput.Value(); got != "second" {
+		t.Errorf("composer %q, want the cancelled message", got)
+	}
+}
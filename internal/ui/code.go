@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Code blocks are fenced (``` or ~~~, with an optional language) or indented
+// by four spaces or a tab after a blank line. They are never wrapped, long
+// lines are truncated to the bubble width instead.
+
+var (
+	codeKeywordStyle  = lipgloss.NewStyle().Foreground(colPrimary)
+	codeNameStyle     = lipgloss.NewStyle().Foreground(colPrimaryMuted)
+	codeStringStyle   = lipgloss.NewStyle().Foreground(colSuccess)
+	codeNumberStyle   = lipgloss.NewStyle().Foreground(colWarning)
+	codeCommentStyle  = lipgloss.NewStyle().Foreground(colMuted).Italic(true)
+	codeDeletedStyle  = lipgloss.NewStyle().Foreground(colDanger)
+	codeInsertedStyle = lipgloss.NewStyle().Foreground(colSuccess)
+)
+
+var (
+	codeFenceRe  = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	codeIndentRe = regexp.MustCompile(`^(    |\t)`)
+)
+
+const codeTabWidth = 4
+
+// segment is a run of message content, either prose or a code block
+type segment struct {
+	text string
+	code bool
+	lang string
+}
+
+// splitCode cuts s into prose and code block segments
+func splitCode(s string) []segment {
+	var segs []segment
+	var prose []string
+	flush := func() {
+		if len(prose) > 0 {
+			segs = append(segs, segment{text: strings.Join(prose, "\n")})
+			prose = nil
+		}
+	}
+
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if m := codeFenceRe.FindStringSubmatch(l); m != nil {
+			fence := m[1]
+			var code []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+					break
+				}
+				code = append(code, lines[j])
+			}
+			flush()
+			segs = append(segs, segment{text: strings.Join(code, "\n"), code: true, lang: m[2]})
+			i = j
+			continue
+		}
+
+		afterBlank := i == 0 || strings.TrimSpace(lines[i-1]) == ""
+		if afterBlank && codeIndentRe.MatchString(l) && strings.TrimSpace(l) != "" {
+			var code []string
+			j := i
+			for ; j < len(lines); j++ {
+				if codeIndentRe.MatchString(lines[j]) {
+					code = append(code, codeIndentRe.ReplaceAllString(lines[j], ""))
+				} else if strings.TrimSpace(lines[j]) == "" {
+					code = append(code, "")
+				} else {
+					break
+				}
+			}
+			// trailing blank lines belong to the prose
+			n := len(code)
+			for n > 0 && strings.TrimSpace(code[n-1]) == "" {
+				n--
+			}
+			flush()
+			segs = append(segs, segment{text: strings.Join(code[:n], "\n"), code: true})
+			i += n - 1
+			continue
+		}
+		prose = append(prose, l)
+	}
+	flush()
+	return segs
+}
+
+// codeBlocks returns the code blocks of s
+func codeBlocks(s string) []string {
+	var blocks []string
+	for _, seg := range splitCode(s) {
+		if seg.code {
+			blocks = append(blocks, seg.text)
+		}
+	}
+	return blocks
+}
+
+// renderContent renders the message content with its code blocks highlighted
+// and truncated to width, prose is rendered as Markdown when markdown is set
+func renderContent(s string, markdown bool, width int) string {
+	segs := splitCode(s)
+	out := make([]string, len(segs))
+	for i, seg := range segs {
+		switch {
+		case seg.code:
+			out[i] = highlightCode(seg.text, seg.lang, width)
+		case markdown:
+			out[i] = renderMarkdown(seg.text)
+		default:
+			out[i] = seg.text
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// highlightCode colors code in the lipgloss palette, the lexer is picked by
+// the fence language or guessed from the code
+func highlightCode(code, lang string, width int) string {
+	code = strings.ReplaceAll(code, "\t", strings.Repeat(" ", codeTabWidth))
+
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	var lines []string
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		lines = strings.Split(code, "\n")
+	} else {
+		for _, tokens := range chroma.SplitTokensIntoLines(it.Tokens()) {
+			var b strings.Builder
+			for _, t := range tokens {
+				v := strings.TrimRight(t.Value, "\n")
+				if strings.TrimSpace(v) == "" {
+					b.WriteString(v)
+					continue
+				}
+				b.WriteString(tokenStyle(t.Type).Render(v))
+			}
+			lines = append(lines, b.String())
+		}
+	}
+	// the lexers end the code with a newline
+	if n := len(lines); n > 0 && ansi.StringWidth(lines[n-1]) == 0 && !strings.HasSuffix(code, "\n") {
+		lines = lines[:n-1]
+	}
+
+	for i, l := range lines {
+		lines[i] = ansi.Truncate(l, width, "…")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tokenStyle(t chroma.TokenType) lipgloss.Style {
+	switch {
+	case t.InCategory(chroma.Comment):
+		return codeCommentStyle
+	case t == chroma.GenericDeleted || t == chroma.Error:
+		return codeDeletedStyle
+	case t == chroma.GenericInserted:
+		return codeInsertedStyle
+	case t.InCategory(chroma.Keyword):
+		return codeKeywordStyle
+	case t.InSubCategory(chroma.LiteralString):
+		return codeStringStyle
+	case t.InSubCategory(chroma.LiteralNumber):
+		return codeNumberStyle
+	case t == chroma.NameFunction || t == chroma.NameClass || t == chroma.NameBuiltin || t == chroma.NameTag:
+		return codeNameStyle
+	}
+	return lipgloss.NewStyle()
+}
+
+// copyCode copies the next code block of the selected message to the
+// clipboard of the terminal with an OSC 52 sequence
+func (m model) copyCode() (model, tea.Cmd) {
+	msg := m.chats.selected
+	if msg == nil {
+		return m, nil
+	}
+	blocks := codeBlocks(msg.Content)
+	if len(blocks) == 0 {
+		m.chats.notice = "No code block in this message"
+		return m, nil
+	}
+	i := m.chats.selectedCode % len(blocks)
+	m.chats.selectedCode++
+	if len(blocks) > 1 {
+		m.chats.notice = fmt.Sprintf("Copied code block %d of %d", i+1, len(blocks))
+	} else {
+		m.chats.notice = "Copied the code block"
+	}
+
+	seq := osc52.New(blocks[i])
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	}
+	return m, func() tea.Msg {
+		if _, err := seq.WriteTo(os.Stderr); err != nil {
+			return attachmentResult{err: err}
+		}
+		return nil
+	}
+}
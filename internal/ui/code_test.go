@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []segment
+	}{
+		{
+			name: "prose only",
+			in:   "Hello\n    not code, no blank line before",
+			want: []segment{{text: "Hello\n    not code, no blank line before"}},
+		},
+		{
+			name: "fenced with language",
+			in:   "See:\n```go\nfunc main() {\n\tprintln()\n}\n```\nok?",
+			want: []segment{
+				{text: "See:"},
+				{text: "func main() {\n\tprintln()\n}", code: true, lang: "go"},
+				{text: "ok?"},
+			},
+		},
+		{
+			name: "unclosed fence runs to the end",
+			in:   "~~~\nx = 1\ny = 2",
+			want: []segment{{text: "x = 1\ny = 2", code: true}},
+		},
+		{
+			name: "indented block",
+			in:   "Trace:\n\n    at a.b(c.java:1)\n\n\tat d.e(f.java:2)\n\nThanks",
+			want: []segment{
+				{text: "Trace:\n"},
+				{text: "at a.b(c.java:1)\n\nat d.e(f.java:2)", code: true},
+				{text: "\nThanks"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitCode(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCode(%q)\n got: %#v\nwant: %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
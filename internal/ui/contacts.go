@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"log"
+	"mchat/internal/models"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	contactNameStyle  = lipgloss.NewStyle().Foreground(colPrimary).Bold(true)
+	contactMutedStyle = lipgloss.NewStyle().Foreground(colMuted)
+)
+
+// contactsResult carries the address book loaded at startup
+type contactsResult struct {
+	contacts []*models.Contact
+	err      error
+}
+
+// contactResult reports the outcome of adding a contact to the address book
+type contactResult struct {
+	contact *models.Contact
+	err     error
+}
+
+func (m model) loadContacts() tea.Msg {
+	contacts, err := m.svc.GetContacts()
+	return contactsResult{contacts: contacts, err: err}
+}
+
+// addContact opens an empty chat with the contact unless one exists
+func (m model) addContact(c *models.Contact) model {
+	if len(c.Emails) == 0 {
+		return m
+	}
+	address := m.svc.NormalizeAddress(c.Emails[0])
+	for _, chat := range m.chats.chats {
+		if chat.Address == address {
+			return m
+		}
+	}
+	return m.addChat(&models.Chat{Address: address, Name: c.Name})
+}
+
+// contactCard renders a contact shared in a message, actions adds the key
+// importing it
+func contactCard(c *models.Contact, actions bool) string {
+	lines := []string{contactNameStyle.Render("👤 " + c.Name)}
+	if c.Organization != "" {
+		lines = append(lines, "🏢 "+c.Organization)
+	}
+	for _, e := range c.Emails {
+		lines = append(lines, "✉ "+e)
+	}
+	for _, p := range c.Phones {
+		lines = append(lines, "☎ "+p)
+	}
+	if actions {
+		lines = append(lines, contactMutedStyle.Render("i add to contacts"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// importContact adds the contact shared in the selected message to the
+// address book
+func (m model) importContact() (model, tea.Cmd) {
+	msg := m.chats.selected
+	if msg == nil || msg.Card == nil {
+		return m, nil
+	}
+	c := msg.Card
+	return m, func() tea.Msg {
+		return contactResult{contact: c, err: m.svc.SaveContact(c)}
+	}
+}
+
+func (m model) updateContacts(msg tea.Msg) model {
+	switch msg := msg.(type) {
+	case contactsResult:
+		if msg.err != nil {
+			log.Println("error while loading the contacts", msg.err)
+		}
+		for _, c := range msg.contacts {
+			m = m.addContact(c)
+		}
+	case contactResult:
+		if msg.err != nil {
+			log.Println("error while saving the contact", msg.err)
+			m.chats.notice = errorNotice(msg.err)
+			return m
+		}
+		m = m.addContact(msg.contact)
+		m.chats.notice = "Added " + msg.contact.Name + " to contacts"
+	}
+	return m
+}
@@ -21,12 +21,22 @@ func (m model) updateHelp(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) viewHelp() string {
 	help := "Controls:\n"
-	help += "• ←,↑,→,↓ or h,k,j,l: list navigation, scrolling\n"
+	help += "• ←,↑,→,↓ or h,k,j,l: list navigation, scrolling (also u,d,b,f or space by half and full pages)\n"
 	help += "• enter or tab: confirm\n"
 	help += "• esc or shift+tab: go back\n"
 	help += "• c: enter config\n"
+	help += "• z: undo sending the last message\n"
+	help += "• ctrl+o / ctrl+x: attach a file / drop the last attached file\n"
+	help += "• J,K or shift+↓,↑: select a message\n"
+	help += "• n: select the next attachment, s: save it, o: open it\n"
+	help += "• e: expand or collapse the quoted text\n"
+	help += "• y: copy a code block of the message to the clipboard\n"
+	help += "• A: answer an invitation, accept, maybe or decline\n"
+	help += "• i: add a contact shared in the message to the contacts\n"
+	help += "• P: encrypt the messages of the chat with OpenPGP, or stop\n"
+	help += "• U: unsubscribe from the mailing list of the channel\n"
+	help += "• M: merge the chat into the chat of another address of the person\n"
 	help += "• r: refresh (not implemented yet)\n"
-	help += "• a: add a chat (not implemented yet)\n"
 	help += "• q: quit\n"
 	help += "\n"
 	help += "Instructions:\n"
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"log"
+	"mchat/internal/models"
+	"mchat/pkg/mlist"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listsResult carries the mailing lists of the channels
+type listsResult struct {
+	lists map[string]*mlist.List
+	err   error
+}
+
+// unsubscribeResult reports the outcome of leaving a mailing list, url is a
+// page to unsubscribe from when it can't be done from mchat
+type unsubscribeResult struct {
+	id  string
+	url string
+	err error
+}
+
+func (m model) loadLists() tea.Msg {
+	lists, err := m.svc.Lists()
+	return listsResult{lists: lists, err: err}
+}
+
+// channelName names the channel of a mailing list by its description, or
+// the first label of its List-Id
+func (m model) channelName(id string) string {
+	if l := m.chats.lists[id]; l != nil && l.Name != "" {
+		return l.Name
+	}
+	name, _, _ := strings.Cut(id, ".")
+	return name
+}
+
+// confirmUnsubscribe asks before leaving the mailing list of the open
+// channel
+func (m model) confirmUnsubscribe() model {
+	if !models.IsChannel(m.chats.chats[m.chats.contactsList.Index()].Address) {
+		m.chats.notice = "Only mailing lists can be unsubscribed from"
+		return m
+	}
+	return m.openPrompt(promptUnsubscribe)
+}
+
+// unsubscribe leaves the mailing list of the open channel
+func (m model) unsubscribe() (model, tea.Cmd) {
+	chat := m.chats.chats[m.chats.contactsList.Index()]
+	id, ok := models.ChannelId(chat.Address)
+	if !ok {
+		return m, nil
+	}
+	m.chats.notice = "Unsubscribing from " + m.channelName(id) + "…"
+	return m, func() tea.Msg {
+		url, err := m.svc.Unsubscribe(chat.Address)
+		return unsubscribeResult{id: id, url: url, err: err}
+	}
+}
+
+func (m model) updateLists(msg tea.Msg) model {
+	switch msg := msg.(type) {
+	case listsResult:
+		if msg.err != nil {
+			log.Println("error while loading the mailing lists", msg.err)
+			return m
+		}
+		for id, l := range msg.lists {
+			m.chats.lists[id] = l
+		}
+	case unsubscribeResult:
+		switch {
+		case msg.err != nil:
+			m.chats.notice = errorNotice(msg.err)
+		case msg.url != "":
+			m.chats.notice = "Open " + msg.url + " to unsubscribe"
+		default:
+			m.chats.notice = "Unsubscribed from " + m.channelName(msg.id)
+		}
+		return m
+	}
+	return m.refreshChatItems()
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The composer accepts the subset of Markdown people write in chats:
+// emphasis, inline code and lists, code blocks are rendered in code.go
+
+var (
+	mdBoldStyle   = lipgloss.NewStyle().Bold(true)
+	mdItalicStyle = lipgloss.NewStyle().Italic(true)
+	mdCodeStyle   = lipgloss.NewStyle().Foreground(colWarning)
+)
+
+var (
+	mdListRe   = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdBoldRe   = regexp.MustCompile(`\*\*([^*\n]+)\*\*|__([^_\n]+)__`)
+	mdItalicRe = regexp.MustCompile(`\*([^*\s][^*\n]*)\*|(^|[^\w])_([^_\s][^_\n]*)_($|[^\w])`)
+)
+
+func renderMarkdown(s string) string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if m := mdListRe.FindStringSubmatch(l); m != nil {
+			bullet := m[2]
+			if bullet == "-" || bullet == "*" || bullet == "+" {
+				bullet = "•"
+			}
+			out = append(out, m[1]+bullet+" "+renderInline(m[3]))
+			continue
+		}
+		out = append(out, renderInline(l))
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderInline styles the emphasis and code spans of a line
+func renderInline(l string) string {
+	parts := strings.Split(l, "`")
+	if len(parts)%2 == 0 {
+		// an unclosed backtick is plain text
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	for i, p := range parts {
+		if i%2 == 1 {
+			parts[i] = mdCodeStyle.Render(p)
+			continue
+		}
+		p = mdBoldRe.ReplaceAllStringFunc(p, func(s string) string {
+			m := mdBoldRe.FindStringSubmatch(s)
+			return mdBoldStyle.Render(m[1] + m[2])
+		})
+		p = mdItalicRe.ReplaceAllStringFunc(p, func(s string) string {
+			m := mdItalicRe.FindStringSubmatch(s)
+			if m[1] != "" {
+				return mdItalicStyle.Render(m[1])
+			}
+			return m[2] + mdItalicStyle.Render(m[3]) + m[4]
+		})
+		parts[i] = p
+	}
+	return strings.Join(parts, "")
+}
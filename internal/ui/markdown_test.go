@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// withANSI renders the styles with escape codes for the test, so that the
+// expected styling shows in the output
+func withANSI(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+}
+
+func TestRenderInline(t *testing.T) {
+	withANSI(t)
+	b, i, c := mdBoldStyle.Render, mdItalicStyle.Render, mdCodeStyle.Render
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Hello there", "Hello there"},
+		{"bold", "a **big** deal", "a " + b("big") + " deal"},
+		{"bold underscores", "a __big__ deal", "a " + b("big") + " deal"},
+		{"italic", "an *odd* one", "an " + i("odd") + " one"},
+		{"italic underscores", "an _odd_ one", "an " + i("odd") + " one"},
+		{"italic inside a word", "a*b*c", "a" + i("b") + "c"},
+		{"snake_case is not italic", "set snake_case_name to 1", "set snake_case_name to 1"},
+		{"spaced asterisks are not italic", "2 * 3 * 4", "2 * 3 * 4"},
+		{"bold and italic", "**now** or *never*", b("now") + " or " + i("never")},
+		{"code", "run `go test` first", "run " + c("go test") + " first"},
+		{"no emphasis in code", "`**kwargs` and `*p`", c("**kwargs") + " and " + c("*p")},
+		{"odd number of backticks", "`a` and a stray ` here", c("a") + " and a stray ` here"},
+		{"single backtick", "it`s", "it`s"},
+		{"unclosed bold", "**not closed", "**not closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderInline(tt.in); got != tt.want {
+				t.Errorf("renderInline(%q)\n got: %q\nwant: %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	withANSI(t)
+	b, c := mdBoldStyle.Render, mdCodeStyle.Render
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bullets", "- one\n* two\n+ three", "• one\n• two\n• three"},
+		{"nested bullet", "- one\n  - two", "• one\n  • two"},
+		{"numbered", "1. one\n2) two", "1. one\n2) two"},
+		{"bold in a list item", "- **done** item", "• " + b("done") + " item"},
+		{"code in a list item", "1. run `make`", "1. run " + c("make")},
+		{"bold line is not a bullet", "**Note** read this", b("Note") + " read this"},
+		{"dash without space is text", "-1 degrees", "-1 degrees"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.in); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got: %q\nwant: %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"log"
+	"mchat/internal/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// chatSettingsResult carries the chat settings loaded at startup
+type chatSettingsResult struct {
+	settings map[string]*models.ChatSettings
+	err      error
+}
+
+// encryptResult reports the outcome of turning a chat encryption on or off
+type encryptResult struct {
+	address string
+	encrypt bool
+	err     error
+}
+
+func (m model) loadChatSettings() tea.Msg {
+	settings, err := m.svc.ChatSettings()
+	return chatSettingsResult{settings: settings, err: err}
+}
+
+// chatItem is the entry of a chat in the contacts list, encrypted chats
+// are marked with a lock
+func (m model) chatItem(c *models.Chat) contactItem {
+	if id, ok := models.ChannelId(c.Address); ok {
+		return contactItem{title: "# " + m.channelName(id), description: id}
+	}
+	item := contactItem{title: c.Name, description: c.Address}
+	if s := m.chats.settings[c.Address]; s != nil && s.Encrypt {
+		item.description = "🔒 " + c.Address
+	}
+	return item
+}
+
+// toggleEncrypt turns the OpenPGP encryption of the open chat on or off
+func (m model) toggleEncrypt() (model, tea.Cmd) {
+	chat := m.chats.chats[m.chats.contactsList.Index()]
+	encrypt := true
+	if s := m.chats.settings[chat.Address]; s != nil {
+		encrypt = !s.Encrypt
+	}
+	return m, func() tea.Msg {
+		return encryptResult{address: chat.Address, encrypt: encrypt, err: m.svc.SetEncrypt(chat.Address, encrypt)}
+	}
+}
+
+func (m model) updateChatSettings(msg tea.Msg) model {
+	switch msg := msg.(type) {
+	case chatSettingsResult:
+		if msg.err != nil {
+			log.Println("error while loading the chat settings", msg.err)
+			return m
+		}
+		for address, s := range msg.settings {
+			m.chats.settings[address] = s
+		}
+	case encryptResult:
+		if msg.err != nil {
+			log.Println("error while changing the chat encryption", msg.err)
+			m.chats.notice = errorNotice(msg.err)
+			return m
+		}
+		m.chats.settings[msg.address] = &models.ChatSettings{Encrypt: msg.encrypt}
+		if msg.encrypt {
+			m.chats.notice = "🔒 Messages to " + msg.address + " are encrypted"
+		} else {
+			m.chats.notice = "Messages to " + msg.address + " are sent unencrypted"
+		}
+	}
+
+	return m.refreshChatItems()
+}
+
+// securityBadge tells whether the message was encrypted and the outcome of
+// its OpenPGP or S/MIME signature verification, with the signer of received
+// messages
+func securityBadge(msg *models.Message) string {
+	var badge string
+	if msg.Encrypted {
+		badge = "🔒"
+	}
+	var sig string
+	switch msg.Signature {
+	case models.SignatureValid:
+		sig = lipgloss.NewStyle().Foreground(colSuccess).Render("✓ signed")
+	case models.SignatureInvalid:
+		sig = lipgloss.NewStyle().Foreground(colDanger).Render("✗ bad signature")
+	case models.SignatureUnknownKey:
+		// an OpenPGP key not in the keyring or an untrusted certificate
+		sig = lipgloss.NewStyle().Foreground(colWarning).Render("? unverified signer")
+	}
+	if sig != "" && !msg.Outgoing && msg.Signer != "" {
+		sig += lipgloss.NewStyle().Foreground(colMuted).Render(" " + msg.Signer)
+	}
+	if badge != "" && sig != "" {
+		return badge + " " + sig
+	}
+	return badge + sig
+}
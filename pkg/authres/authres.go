@@ -0,0 +1,253 @@
+// Package authres reads the Authentication-Results header of RFC 8601 and
+// the ARC-Authentication-Results header of RFC 8617, in which the receiving
+// server records the outcome of the DKIM, SPF and DMARC checks of a message.
+package authres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Result is the outcome of one authentication method
+type Result struct {
+	// Method is dkim, spf, dmarc, arc...
+	Method string
+	// Value is pass, fail, none, neutral, softfail, temperror, permerror...
+	Value  string
+	Reason string
+	// Props holds the properties by ptype.property, such as header.d
+	Props map[string]string
+}
+
+// Results is a parsed header
+type Results struct {
+	// AuthServId identifies the server that checked the message, some
+	// servers leave it out
+	AuthServId string
+	// Instance is the i= tag of an ARC header
+	Instance int
+	Results  []Result
+}
+
+// Verdict summarizes the results for the sender address
+type Verdict int
+
+const (
+	// None means that nothing tells whether the sender is genuine
+	None Verdict = iota
+	Pass
+	Fail
+)
+
+// Parse parses the value of an Authentication-Results header
+func Parse(value string) (*Results, error) {
+	fields := splitFields(stripComments(value))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("authres: empty header")
+	}
+	r := &Results{}
+	if !strings.Contains(fields[0], "=") {
+		// authserv-id, optionally followed by a version
+		r.AuthServId = strings.ToLower(strings.Fields(fields[0])[0])
+		fields = fields[1:]
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f, "none") {
+			continue
+		}
+		result, err := parseResult(f)
+		if err != nil {
+			return nil, err
+		}
+		r.Results = append(r.Results, result)
+	}
+	return r, nil
+}
+
+// ParseARC parses the value of an ARC-Authentication-Results header, the
+// instance tag comes before the authserv-id
+func ParseARC(value string) (*Results, error) {
+	tag, rest, ok := strings.Cut(stripComments(value), ";")
+	name, instance, _ := strings.Cut(strings.TrimSpace(tag), "=")
+	if !ok || strings.TrimSpace(name) != "i" {
+		return nil, fmt.Errorf("authres: missing ARC instance")
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(instance))
+	if err != nil {
+		return nil, fmt.Errorf("authres: invalid ARC instance %q", instance)
+	}
+	r, err := Parse(rest)
+	if err != nil {
+		return nil, err
+	}
+	r.Instance = i
+	return r, nil
+}
+
+func parseResult(field string) (Result, error) {
+	tokens := splitTokens(field)
+	method, value, ok := strings.Cut(tokens[0], "=")
+	if !ok || method == "" {
+		return Result{}, fmt.Errorf("authres: invalid result %q", field)
+	}
+	// the method may carry a version, dkim/1
+	method, _, _ = strings.Cut(method, "/")
+	r := Result{Method: strings.ToLower(method), Value: strings.ToLower(value), Props: make(map[string]string)}
+	for _, t := range tokens[1:] {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(v, `"`)
+		if strings.EqualFold(k, "reason") {
+			r.Reason = v
+		} else if strings.Contains(k, ".") {
+			r.Props[strings.ToLower(k)] = v
+		}
+	}
+	return r, nil
+}
+
+// stripComments removes the parenthesized comments outside quoted strings
+func stripComments(s string) string {
+	var b strings.Builder
+	depth, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (quoted || depth > 0):
+			if depth == 0 {
+				b.WriteByte(c)
+				b.WriteByte(s[i+1])
+			}
+			i++
+		case c == '"' && depth == 0:
+			quoted = !quoted
+			b.WriteByte(c)
+		case c == '(' && !quoted:
+			depth++
+		case c == ')' && !quoted && depth > 0:
+			depth--
+			if depth == 0 {
+				b.WriteByte(' ')
+			}
+		case depth == 0:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// splitFields splits at the semicolons outside quoted strings, dropping
+// the empty fields
+func splitFields(s string) []string {
+	var fields []string
+	quoted, start := false, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && s[i] == '"' {
+			quoted = !quoted
+		}
+		if i == len(s) || (s[i] == ';' && !quoted) {
+			if f := strings.TrimSpace(s[start:i]); f != "" {
+				fields = append(fields, f)
+			}
+			start = i + 1
+		}
+	}
+	return fields
+}
+
+// splitTokens splits a result at the whitespace outside quoted strings,
+// spaces around the equal signs are joined
+func splitTokens(s string) []string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(strings.ReplaceAll(s, " =", "="), "= ", "=")
+	var tokens []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case r == ' ' && !quoted:
+			tokens = append(tokens, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(tokens, b.String())
+}
+
+// Verdict tells whether the sender address, in fromDomain, is genuine.
+// DMARC decides when it was checked, otherwise a DKIM signature or an SPF
+// check of a domain aligned with fromDomain.
+func (r *Results) Verdict(fromDomain string) Verdict {
+	fromDomain = strings.ToLower(fromDomain)
+	if dmarc := r.find("dmarc"); dmarc != nil {
+		switch dmarc.Value {
+		case "pass":
+			return Pass
+		case "fail":
+			return Fail
+		}
+		return None
+	}
+
+	verdict := None
+	for _, res := range r.Results {
+		var domain string
+		switch res.Method {
+		case "dkim":
+			domain = res.Props["header.d"]
+			if domain == "" {
+				_, domain, _ = strings.Cut(res.Props["header.i"], "@")
+			}
+		case "spf":
+			domain = res.Props["smtp.mailfrom"]
+			if _, d, ok := strings.Cut(domain, "@"); ok {
+				domain = d
+			}
+		default:
+			continue
+		}
+		if !aligned(strings.ToLower(domain), fromDomain) {
+			continue
+		}
+		switch res.Value {
+		case "pass":
+			return Pass
+		case "fail", "permerror":
+			verdict = Fail
+		}
+	}
+	return verdict
+}
+
+func (r *Results) find(method string) *Result {
+	for i := range r.Results {
+		if r.Results[i].Method == method {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+// aligned is the relaxed alignment of DMARC: the same domain or a parent
+func aligned(domain, fromDomain string) bool {
+	return domain != "" && (domain == fromDomain || strings.HasSuffix(fromDomain, "."+domain))
+}
+
+// Summary lists the results of the DKIM, SPF and DMARC checks, such as
+// "dkim=pass spf=pass dmarc=pass"
+func (r *Results) Summary() string {
+	var parts []string
+	for _, method := range []string{"dkim", "spf", "dmarc"} {
+		if res := r.find(method); res != nil {
+			parts = append(parts, method+"="+res.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,111 @@
+package authres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Results
+	}{
+		{
+			name: "gmail",
+			in: "mx.google.com;\r\n       dkim=pass header.i=@example.com header.s=20230601 header.b=Ab1/cD;\r\n" +
+				"       spf=pass (google.com: domain of anna@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=anna@example.com;\r\n" +
+				"       dmarc=pass (p=NONE sp=NONE dis=NONE) header.from=example.com",
+			want: Results{AuthServId: "mx.google.com", Results: []Result{
+				{Method: "dkim", Value: "pass", Props: map[string]string{"header.i": "@example.com", "header.s": "20230601", "header.b": "Ab1/cD"}},
+				{Method: "spf", Value: "pass", Props: map[string]string{"smtp.mailfrom": "anna@example.com"}},
+				{Method: "dmarc", Value: "pass", Props: map[string]string{"header.from": "example.com"}},
+			}},
+		},
+		{
+			name: "outlook without authserv-id",
+			in: "spf=pass (sender IP is 192.0.2.1) smtp.mailfrom=example.com; dkim=pass (signature was verified)\r\n" +
+				" header.d=example.com;dmarc=fail action=oreject header.from=example.com;compauth=fail reason=000",
+			want: Results{Results: []Result{
+				{Method: "spf", Value: "pass", Props: map[string]string{"smtp.mailfrom": "example.com"}},
+				{Method: "dkim", Value: "pass", Props: map[string]string{"header.d": "example.com"}},
+				{Method: "dmarc", Value: "fail", Props: map[string]string{"header.from": "example.com"}},
+				{Method: "compauth", Value: "fail", Reason: "000", Props: map[string]string{}},
+			}},
+		},
+		{
+			name: "version, quoted reason and nested comment",
+			in:   `Example.org 1; dkim/1 = fail reason="signature (didn't) verify" (bad (really)) header.d=example.com`,
+			want: Results{AuthServId: "example.org", Results: []Result{
+				{Method: "dkim", Value: "fail", Reason: "signature (didn't) verify", Props: map[string]string{"header.d": "example.com"}},
+			}},
+		},
+		{
+			name: "no results",
+			in:   "mx.example.net; none",
+			want: Results{AuthServId: "mx.example.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseARC(t *testing.T) {
+	r, err := ParseARC("i=2; mx.google.com; dkim=pass header.i=@lists.example.org; dmarc=pass header.from=example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Instance != 2 || r.AuthServId != "mx.google.com" || len(r.Results) != 2 {
+		t.Errorf("got %+v", r)
+	}
+	if _, err := ParseARC("mx.google.com; dkim=pass"); err == nil {
+		t.Error("a header without instance should fail")
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		in   string
+		from string
+		want Verdict
+	}{
+		{"mx; dkim=pass header.d=example.com; dmarc=fail header.from=example.com", "example.com", Fail},
+		{"mx; dkim=fail header.d=example.com; dmarc=pass header.from=example.com", "example.com", Pass},
+		{"mx; dmarc=none header.from=example.com; dkim=pass header.d=example.com", "example.com", None},
+		{"mx; dkim=pass header.d=example.com", "mail.example.com", Pass},
+		{"mx; dkim=pass header.i=@example.com", "example.com", Pass},
+		{"mx; dkim=pass header.d=attacker.example; spf=pass smtp.mailfrom=bounce@attacker.example", "example.com", None},
+		{"mx; dkim=fail header.d=example.com; spf=softfail smtp.mailfrom=example.com", "example.com", Fail},
+		{"mx; dkim=fail header.d=example.com; spf=pass smtp.mailfrom=a@example.com", "example.com", Pass},
+		{"mx; spf=neutral smtp.mailfrom=example.com", "example.com", None},
+		{"mx; none", "example.com", None},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Verdict(tt.from); got != tt.want {
+			t.Errorf("%q from %s: got %v, want %v", tt.in, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r, err := Parse("mx; spf=softfail smtp.mailfrom=example.com; arc=none; dkim=pass header.d=example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Summary(); got != "dkim=pass spf=softfail" {
+		t.Errorf("summary = %q", got)
+	}
+}
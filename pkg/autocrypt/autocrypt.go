@@ -0,0 +1,159 @@
+// Package autocrypt implements Autocrypt Level 1, the opportunistic OpenPGP
+// key exchange of https://autocrypt.org/level1.html: the Autocrypt header,
+// the peer state it updates and the encryption recommendation derived from
+// it, and the Setup Message moving the secret key between devices.
+//
+// Keys are handled as the binary OpenPGP packets of the keydata attribute,
+// the caller parses them.
+package autocrypt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Values of the prefer-encrypt attribute
+const (
+	PreferMutual       = "mutual"
+	PreferNoPreference = "nopreference"
+)
+
+// staleAfter is how long a key keeps being recommended after the last
+// message carrying it, when newer messages don't
+const staleAfter = 35 * 24 * time.Hour
+
+// keydataLineLength is the length of the keydata chunks, separated by
+// spaces so that the header can be folded
+const keydataLineLength = 72
+
+// Header is the Autocrypt header of a message
+type Header struct {
+	Addr          string
+	PreferEncrypt string
+	KeyData       []byte
+}
+
+// ParseHeader parses the value of an Autocrypt header. Unknown critical
+// attributes make the header invalid, the non-critical ones start with an
+// underscore and are ignored.
+func ParseHeader(value string) (*Header, error) {
+	h := &Header{PreferEncrypt: PreferNoPreference}
+	var keydata string
+	for _, attr := range strings.Split(value, ";") {
+		attr = strings.TrimSpace(attr)
+		if attr == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(attr, "=")
+		if !ok {
+			return nil, fmt.Errorf("autocrypt: invalid attribute %q", attr)
+		}
+		switch name = strings.TrimSpace(name); name {
+		case "addr":
+			h.Addr = strings.TrimSpace(v)
+		case "prefer-encrypt":
+			if strings.TrimSpace(v) == PreferMutual {
+				h.PreferEncrypt = PreferMutual
+			}
+		case "keydata":
+			keydata = v
+		default:
+			if !strings.HasPrefix(name, "_") {
+				return nil, fmt.Errorf("autocrypt: unknown critical attribute %q", name)
+			}
+		}
+	}
+	if h.Addr == "" || keydata == "" {
+		return nil, fmt.Errorf("autocrypt: addr and keydata are required")
+	}
+	var err error
+	h.KeyData, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(keydata), ""))
+	if err != nil {
+		return nil, fmt.Errorf("autocrypt: keydata: %w", err)
+	}
+	return h, nil
+}
+
+// String formats the header value, the keydata is split in chunks
+func (h *Header) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "addr=%s;", h.Addr)
+	if h.PreferEncrypt == PreferMutual {
+		b.WriteString(" prefer-encrypt=mutual;")
+	}
+	b.WriteString(" keydata=")
+	keydata := base64.StdEncoding.EncodeToString(h.KeyData)
+	for len(keydata) > keydataLineLength {
+		b.WriteString(" " + keydata[:keydataLineLength])
+		keydata = keydata[keydataLineLength:]
+	}
+	b.WriteString(" " + keydata)
+	return b.String()
+}
+
+// Peer is what is known of the key of a correspondent
+type Peer struct {
+	Addr string
+	// LastSeen is the date of the most recent message from the peer
+	LastSeen time.Time
+	// Timestamp is the date of the most recent message with a header
+	Timestamp     time.Time
+	KeyData       []byte
+	PreferEncrypt string
+}
+
+// Update records a message from the peer sent at date, h is its Autocrypt
+// header or nil. It tells whether the state changed.
+func (p *Peer) Update(date time.Time, h *Header) bool {
+	// a date in the future must not prevent later updates
+	if now := time.Now(); date.After(now) {
+		date = now
+	}
+	if !date.After(p.LastSeen) {
+		return false
+	}
+	p.LastSeen = date
+	if h != nil && date.After(p.Timestamp) {
+		p.Timestamp = date
+		p.KeyData = h.KeyData
+		p.PreferEncrypt = h.PreferEncrypt
+	}
+	return true
+}
+
+// Recommendation tells whether a message to a peer should be encrypted
+type Recommendation int
+
+const (
+	// Disable encryption, there is no key
+	Disable Recommendation = iota
+	// Discourage encryption, the key may no longer be in use
+	Discourage
+	// Available means the user may choose to encrypt
+	Available
+	// Encrypt by default
+	Encrypt
+)
+
+// Recommend returns the recommendation for a message to the peer, mutual is
+// the preference of the user and reply tells whether the message answers
+// an encrypted one
+func (p *Peer) Recommend(mutual, reply bool) Recommendation {
+	if p == nil || len(p.KeyData) == 0 {
+		return Disable
+	}
+	r := Available
+	if p.LastSeen.Sub(p.Timestamp) > staleAfter {
+		r = Discourage
+	}
+	switch {
+	case reply:
+		// replies to encrypted messages stay encrypted
+		return Encrypt
+	case r == Available && mutual && p.PreferEncrypt == PreferMutual:
+		return Encrypt
+	}
+	return r
+}
@@ -0,0 +1,163 @@
+package autocrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Header
+		wantErr bool
+	}{
+		{
+			name: "mutual with folded keydata",
+			in:   "addr=alice@example.com; prefer-encrypt=mutual; keydata=\r\n a2V5\r\n ZGF0YQ==",
+			want: Header{Addr: "alice@example.com", PreferEncrypt: PreferMutual, KeyData: []byte("keydata")},
+		},
+		{
+			name: "non-critical attribute",
+			in:   "addr=bob@example.org; _comment=hello; keydata=a2V5ZGF0YQ==",
+			want: Header{Addr: "bob@example.org", PreferEncrypt: PreferNoPreference, KeyData: []byte("keydata")},
+		},
+		{name: "unknown critical attribute", in: "addr=bob@example.org; foo=bar; keydata=a2V5ZGF0YQ==", wantErr: true},
+		{name: "missing keydata", in: "addr=bob@example.org", wantErr: true},
+		{name: "invalid base64", in: "addr=bob@example.org; keydata=!!!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHeader(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", h)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Addr != tt.want.Addr || h.PreferEncrypt != tt.want.PreferEncrypt || !bytes.Equal(h.KeyData, tt.want.KeyData) {
+				t.Errorf("got %+v, want %+v", h, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderString(t *testing.T) {
+	h := &Header{Addr: "alice@example.com", PreferEncrypt: PreferMutual, KeyData: bytes.Repeat([]byte{0xAB}, 200)}
+	s := h.String()
+	if !strings.HasPrefix(s, "addr=alice@example.com; prefer-encrypt=mutual; keydata= ") {
+		t.Errorf("header = %q", s)
+	}
+	for _, word := range strings.Fields(s) {
+		if len(word) > keydataLineLength {
+			t.Errorf("%q can't be folded", word)
+		}
+	}
+	parsed, err := ParseHeader(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.KeyData, h.KeyData) {
+		t.Errorf("keydata changed")
+	}
+}
+
+func TestPeerUpdate(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 1, n, 12, 0, 0, 0, time.UTC) }
+	p := &Peer{Addr: "alice@example.com"}
+
+	if !p.Update(day(2), &Header{KeyData: []byte("k1"), PreferEncrypt: PreferMutual}) {
+		t.Fatal("first message not recorded")
+	}
+	// an older message arriving late doesn't replace the key
+	if p.Update(day(1), &Header{KeyData: []byte("k0")}) || string(p.KeyData) != "k1" {
+		t.Errorf("older message: %+v", p)
+	}
+	// a message without header only moves last seen
+	p.Update(day(3), nil)
+	if string(p.KeyData) != "k1" || !p.LastSeen.Equal(day(3)) || !p.Timestamp.Equal(day(2)) {
+		t.Errorf("message without header: %+v", p)
+	}
+	p.Update(day(4), &Header{KeyData: []byte("k2"), PreferEncrypt: PreferNoPreference})
+	if string(p.KeyData) != "k2" || p.PreferEncrypt != PreferNoPreference {
+		t.Errorf("new key: %+v", p)
+	}
+	if p.Update(time.Now().Add(24*time.Hour), nil); p.LastSeen.After(time.Now()) {
+		t.Errorf("future date recorded: %v", p.LastSeen)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	now := time.Now()
+	fresh := &Peer{LastSeen: now, Timestamp: now, KeyData: []byte("k"), PreferEncrypt: PreferMutual}
+	stale := &Peer{LastSeen: now, Timestamp: now.Add(-60 * 24 * time.Hour), KeyData: []byte("k"), PreferEncrypt: PreferMutual}
+	noPreference := &Peer{LastSeen: now, Timestamp: now, KeyData: []byte("k"), PreferEncrypt: PreferNoPreference}
+
+	tests := []struct {
+		name          string
+		peer          *Peer
+		mutual, reply bool
+		want          Recommendation
+	}{
+		{"unknown peer", nil, true, false, Disable},
+		{"no key", &Peer{LastSeen: now}, true, true, Disable},
+		{"both mutual", fresh, true, false, Encrypt},
+		{"user without preference", fresh, false, false, Available},
+		{"peer without preference", noPreference, true, false, Available},
+		{"stale key", stale, true, false, Discourage},
+		{"reply to encrypted", stale, false, true, Encrypt},
+	}
+	for _, tt := range tests {
+		if got := tt.peer.Recommend(tt.mutual, tt.reply); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetupFile(t *testing.T) {
+	e, err := openpgp.NewEntity("Alice", "", "alice@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := NewSetupCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 44 || strings.Count(code, "-") != 8 {
+		t.Errorf("setup code = %q", code)
+	}
+
+	file, err := SetupFile(e, PreferMutual, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(file, []byte("Passphrase-Begin: "+code[:2])) {
+		t.Errorf("setup file:\n%s", file)
+	}
+
+	if _, _, err := OpenSetupFile(bytes.NewReader(file), strings.Repeat("1", 36)); err == nil {
+		t.Error("opened with a wrong code")
+	}
+	typed, err := NormalizeSetupCode(strings.ReplaceAll(code, "-", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, prefer, err := OpenSetupFile(bytes.NewReader(file), typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].PrivateKey == nil || prefer != PreferMutual {
+		t.Errorf("keys = %v, prefer-encrypt = %q", keys, prefer)
+	}
+	if !bytes.Equal(keys[0].PrimaryKey.Fingerprint, e.PrimaryKey.Fingerprint) {
+		t.Error("another key was restored")
+	}
+}
@@ -0,0 +1,162 @@
+package autocrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Setup Message fields and content types
+const (
+	SetupHeader      = "Autocrypt-Setup-Message"
+	SetupVersion     = "v1"
+	SetupSubject     = "Autocrypt Setup Message"
+	SetupContentType = "application/autocrypt-setup"
+	SetupFilename    = "autocrypt-setup-message.html"
+
+	// SetupDescription is the text part shown by clients that don't
+	// support the Setup Message
+	SetupDescription = "This message contains all information to transfer your Autocrypt\n" +
+		"settings along with your secret key securely from your original\n" +
+		"device.\n\n" +
+		"To set up your new device for Autocrypt, please follow the\n" +
+		"instructions that should be presented by your new device.\n\n" +
+		"You can keep this message and use it as a backup for your secret\n" +
+		"key. If you want to do this, you should write down the Setup Code\n" +
+		"and store it securely.\n"
+)
+
+// preferEncryptHeader carries the preference of the user in the armored key
+const preferEncryptHeader = "Autocrypt-Prefer-Encrypt"
+
+// NewSetupCode returns a random Setup Code of 36 digits in blocks of 4
+func NewSetupCode() (string, error) {
+	digits := make([]byte, 36)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return formatSetupCode(string(digits)), nil
+}
+
+// NormalizeSetupCode formats a Setup Code typed with any separators, it
+// fails unless there are 36 digits
+func NormalizeSetupCode(code string) (string, error) {
+	var digits strings.Builder
+	for _, r := range code {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if digits.Len() != 36 {
+		return "", fmt.Errorf("autocrypt: the setup code has 36 digits, not %d", digits.Len())
+	}
+	return formatSetupCode(digits.String()), nil
+}
+
+func formatSetupCode(digits string) string {
+	blocks := make([]string, 0, 9)
+	for i := 0; i < len(digits); i += 4 {
+		blocks = append(blocks, digits[i:i+4])
+	}
+	return strings.Join(blocks, "-")
+}
+
+// SetupFile encrypts the armored secret key with the Setup Code into the
+// HTML attachment of the Setup Message
+func SetupFile(secretKey *openpgp.Entity, preferEncrypt, code string) ([]byte, error) {
+	var key bytes.Buffer
+	aw, err := armor.Encode(&key, openpgp.PrivateKeyType, map[string]string{preferEncryptHeader: preferEncrypt})
+	if err != nil {
+		return nil, err
+	}
+	if err := secretKey.SerializePrivateWithoutSigning(aw, nil); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	aw, err = armor.Encode(&msg, "PGP MESSAGE", map[string]string{
+		"Passphrase-Format": "numeric9x4",
+		"Passphrase-Begin":  code[:2],
+	})
+	if err != nil {
+		return nil, err
+	}
+	config := &packet.Config{DefaultCipher: packet.CipherAES128}
+	w, err := openpgp.SymmetricallyEncrypt(aw, []byte(code), nil, config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(key.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<html><body><p>This is the Autocrypt setup file used to transfer keys between clients.</p><pre>\n")
+	b.Write(msg.Bytes())
+	b.WriteString("\n</pre></body></html>\n")
+	return b.Bytes(), nil
+}
+
+// OpenSetupFile decrypts the attachment of a Setup Message, it returns the
+// secret key and the preference of the user
+func OpenSetupFile(r io.Reader, code string) (openpgp.EntityList, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	start := bytes.Index(data, []byte("-----BEGIN PGP MESSAGE-----"))
+	if start < 0 {
+		return nil, "", errors.New("autocrypt: no encrypted key in the setup file")
+	}
+	block, err := armor.Decode(bytes.NewReader(data[start:]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	tried := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if tried {
+			return nil, errors.New("autocrypt: wrong setup code")
+		}
+		tried = true
+		return []byte(code), nil
+	}
+	md, err := openpgp.ReadMessage(block.Body, nil, prompt, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := armor.Decode(md.UnverifiedBody)
+	if err != nil {
+		return nil, "", err
+	}
+	entities, err := openpgp.ReadKeyRing(key.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	preferEncrypt := PreferNoPreference
+	if key.Header[preferEncryptHeader] == PreferMutual {
+		preferEncrypt = PreferMutual
+	}
+	return entities, preferEncrypt, nil
+}
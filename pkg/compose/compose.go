@@ -0,0 +1,443 @@
+// Package compose builds RFC 5322 messages with RFC 2045 MIME bodies.
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"mchat/pkg/flowed"
+)
+
+// maxLineLength is the longest line sent without a transfer encoding,
+// RFC 5322 recommends to stay under 78 characters
+const maxLineLength = 76
+
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Header keeps fields in insertion order and preserves the name casing
+type Header []Field
+
+func (h *Header) Add(name, value string) {
+	*h = append(*h, Field{Name: name, Value: value})
+}
+
+func (h Header) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Part is a single MIME entity: a leaf with a body or a multipart container
+type Part struct {
+	ContentType string
+	Params      map[string]string
+	Header      Header
+	Body        []byte
+	Parts       []*Part
+	// Boundary of a multipart container, random when empty
+	Boundary string
+	// TransferEncoding overrides the encoding chosen from the body
+	TransferEncoding string
+}
+
+func TextPart(text string) *Part {
+	return &Part{
+		ContentType: "text/plain",
+		Params:      map[string]string{"charset": "utf-8"},
+		Body:        []byte(text),
+	}
+}
+
+// FlowedTextPart wraps long lines with soft breaks so clients supporting
+// format=flowed reflow them to their width
+func FlowedTextPart(text string) *Part {
+	return &Part{
+		ContentType: "text/plain",
+		Params:      map[string]string{"charset": "utf-8", "format": "flowed"},
+		Body:        []byte(flowed.Encode(text)),
+	}
+}
+
+func HTMLPart(html string) *Part {
+	return &Part{
+		ContentType: "text/html",
+		Params:      map[string]string{"charset": "utf-8"},
+		Body:        []byte(html),
+	}
+}
+
+// CalendarPart carries an iCalendar object of an iTIP method such as
+// REQUEST or REPLY, RFC 6047
+func CalendarPart(ics []byte, method string) *Part {
+	return &Part{
+		ContentType: "text/calendar",
+		Params:      map[string]string{"charset": "utf-8", "method": method},
+		Body:        ics,
+	}
+}
+
+func AttachmentPart(filename, contentType string, data []byte) *Part {
+	p := &Part{
+		ContentType: contentType,
+		Params:      map[string]string{"name": filename},
+		Body:        data,
+	}
+	p.Header.Add("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	return p
+}
+
+// EncryptedPart is the multipart/encrypted body of a PGP/MIME message
+// carrying the armored OpenPGP message, RFC 3156
+func EncryptedPart(armored []byte) *Part {
+	control := &Part{
+		ContentType:      "application/pgp-encrypted",
+		Body:             []byte("Version: 1\n"),
+		TransferEncoding: "7bit",
+	}
+	control.Header.Add("Content-Description", "PGP/MIME version identification")
+	data := &Part{
+		ContentType:      "application/octet-stream",
+		Params:           map[string]string{"name": "encrypted.asc"},
+		Body:             armored,
+		TransferEncoding: "7bit",
+	}
+	data.Header.Add("Content-Description", "OpenPGP encrypted message")
+	data.Header.Add("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "encrypted.asc"}))
+	return &Part{
+		ContentType: "multipart/encrypted",
+		Params:      map[string]string{"protocol": "application/pgp-encrypted"},
+		Parts:       []*Part{control, data},
+	}
+}
+
+// SignedPart is the multipart/signed body of a message, RFC 1847. The
+// signature covers signed as written by WriteTo, which writes it the same
+// way again in the message.
+func SignedPart(signed *Part, micalg string, signature *Part) *Part {
+	return &Part{
+		ContentType: "multipart/signed",
+		Params:      map[string]string{"protocol": signature.ContentType, "micalg": micalg},
+		Parts:       []*Part{signed, signature},
+	}
+}
+
+// SMIMESignaturePart carries the detached DER signature of an S/MIME
+// message, RFC 8551
+func SMIMESignaturePart(der []byte) *Part {
+	p := &Part{
+		ContentType: "application/pkcs7-signature",
+		Params:      map[string]string{"name": "smime.p7s"},
+		Body:        der,
+	}
+	p.Header.Add("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "smime.p7s"}))
+	p.Header.Add("Content-Description", "S/MIME Cryptographic Signature")
+	return p
+}
+
+func MultipartPart(subtype string, parts ...*Part) *Part {
+	return &Part{ContentType: "multipart/" + subtype, Parts: parts}
+}
+
+func (p *Part) isMultipart() bool {
+	return strings.HasPrefix(p.ContentType, "multipart/")
+}
+
+// WriteTo writes the part headers, an empty line and the encoded body
+func (p *Part) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	if err := p.write(cw); err != nil {
+		return cw.n, err
+	}
+	return cw.n, bw.Flush()
+}
+
+func (p *Part) write(w io.Writer) error {
+	params := make(map[string]string, len(p.Params)+1)
+	for k, v := range p.Params {
+		params[k] = v
+	}
+	if p.isMultipart() {
+		if p.Boundary == "" {
+			p.Boundary = randomBoundary()
+		}
+		params["boundary"] = p.Boundary
+	}
+	contentType := mime.FormatMediaType(p.ContentType, params)
+	if contentType == "" {
+		return fmt.Errorf("invalid content type %q", p.ContentType)
+	}
+	writeField(w, "Content-Type", contentType)
+
+	if p.isMultipart() {
+		for _, f := range p.Header {
+			writeField(w, f.Name, encodeValue(f.Value))
+		}
+		fmt.Fprint(w, "\r\n")
+		for _, part := range p.Parts {
+			fmt.Fprintf(w, "--%s\r\n", p.Boundary)
+			if err := part.write(w); err != nil {
+				return err
+			}
+			fmt.Fprint(w, "\r\n")
+		}
+		_, err := fmt.Fprintf(w, "--%s--\r\n", p.Boundary)
+		return err
+	}
+
+	encoding := p.transferEncoding()
+	writeField(w, "Content-Transfer-Encoding", encoding)
+	for _, f := range p.Header {
+		writeField(w, f.Name, encodeValue(f.Value))
+	}
+	fmt.Fprint(w, "\r\n")
+	return writeBody(w, p.Body, encoding)
+}
+
+func (p *Part) isText() bool {
+	return strings.HasPrefix(p.ContentType, "text/")
+}
+
+func (p *Part) transferEncoding() string {
+	if p.TransferEncoding != "" {
+		return p.TransferEncoding
+	}
+	if !p.isText() {
+		return "base64"
+	}
+	if is7bit(p.Body) {
+		return "7bit"
+	}
+	return "quoted-printable"
+}
+
+func is7bit(b []byte) bool {
+	lineLen := 0
+	for _, c := range b {
+		switch {
+		case c == 0 || c >= 0x80:
+			return false
+		case c == '\n':
+			lineLen = 0
+		case c != '\r':
+			lineLen++
+			if lineLen > maxLineLength {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func writeBody(w io.Writer, body []byte, encoding string) error {
+	switch encoding {
+	case "7bit":
+		_, err := io.WriteString(w, toCRLF(string(body)))
+		return err
+	case "quoted-printable":
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write(body); err != nil {
+			return err
+		}
+		return qw.Close()
+	default:
+		enc := base64.StdEncoding.EncodeToString(body)
+		for len(enc) > maxLineLength {
+			if _, err := fmt.Fprintf(w, "%s\r\n", enc[:maxLineLength]); err != nil {
+				return err
+			}
+			enc = enc[maxLineLength:]
+		}
+		_, err := io.WriteString(w, enc)
+		return err
+	}
+}
+
+func toCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+type Message struct {
+	From       *mail.Address
+	To         []*mail.Address
+	Cc         []*mail.Address
+	Subject    string
+	Date       time.Time
+	MessageId  string
+	InReplyTo  string
+	References []string
+	// Header holds additional top-level fields, written after the standard ones
+	Header Header
+	Body   *Part
+}
+
+// WriteTo writes the whole message, filling in the Date and Message-ID if missing
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	if m.From == nil {
+		return 0, fmt.Errorf("message has no sender")
+	}
+	if m.Body == nil {
+		return 0, fmt.Errorf("message has no body")
+	}
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	if m.MessageId == "" {
+		m.MessageId = NewMessageId(m.From.Address)
+	}
+
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	writeField(cw, "From", m.From.String())
+	if len(m.To) > 0 {
+		writeField(cw, "To", formatAddressList(m.To))
+	}
+	if len(m.Cc) > 0 {
+		writeField(cw, "Cc", formatAddressList(m.Cc))
+	}
+	writeField(cw, "Subject", encodeValue(m.Subject))
+	writeField(cw, "Date", m.Date.Format(time.RFC1123Z))
+	writeField(cw, "Message-ID", m.MessageId)
+	if m.InReplyTo != "" {
+		writeField(cw, "In-Reply-To", m.InReplyTo)
+	}
+	if len(m.References) > 0 {
+		writeField(cw, "References", strings.Join(m.References, " "))
+	}
+	writeField(cw, "MIME-Version", "1.0")
+	for _, f := range m.Header {
+		writeField(cw, f.Name, encodeValue(f.Value))
+	}
+	if err := m.Body.write(cw); err != nil {
+		return cw.n, err
+	}
+	if !m.Body.isMultipart() {
+		fmt.Fprint(cw, "\r\n")
+	}
+	return cw.n, bw.Flush()
+}
+
+func (m *Message) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	_, err := m.WriteTo(&b)
+	return b.Bytes(), err
+}
+
+// NewMessageId returns a unique Message-ID in the domain of the given address
+func NewMessageId(addr string) string {
+	domain := "mchat.local"
+	if i := strings.LastIndex(addr, "@"); i >= 0 && i < len(addr)-1 {
+		domain = addr[i+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+func formatAddressList(list []*mail.Address) string {
+	addrs := make([]string, len(list))
+	for i, a := range list {
+		addrs[i] = a.String()
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// maxEncodedText is the encoded text length of a single RFC 2047 word,
+// short enough for the word to fit on the first line after a field name
+const maxEncodedText = 50
+
+// encodeValue turns non-ASCII text into RFC 2047 encoded words, as well as
+// text with control characters: a line break would start another field
+func encodeValue(s string) string {
+	if isPlainASCII(s) {
+		return s
+	}
+	var words []string
+	var word strings.Builder
+	for _, r := range s {
+		var enc string
+		switch {
+		case r == ' ':
+			enc = "_"
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!*+-/", r)):
+			enc = string(r)
+		default:
+			var buf [utf8.UTFMax]byte
+			n := utf8.EncodeRune(buf[:], r)
+			for _, b := range buf[:n] {
+				enc += fmt.Sprintf("=%02X", b)
+			}
+		}
+		if word.Len()+len(enc) > maxEncodedText {
+			words = append(words, word.String())
+			word.Reset()
+		}
+		word.WriteString(enc)
+	}
+	words = append(words, word.String())
+	for i, w := range words {
+		words[i] = "=?utf-8?q?" + w + "?="
+	}
+	return strings.Join(words, " ")
+}
+
+// isPlainASCII tells whether s is printable ASCII, with spaces and tabs
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf || s[i] < ' ' && s[i] != '\t' || s[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// writeField writes a header field folded at whitespace to keep lines short
+func writeField(w io.Writer, name, value string) {
+	line := name + ":"
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && len(line)+1+len(word) > maxLineLength+2 {
+			fmt.Fprintf(w, "%s\r\n", line)
+			line = ""
+		}
+		line += " " + word
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+func randomBoundary() string {
+	return "mchat-" + randomHex(12)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,175 @@
+package compose
+
+import (
+	"bytes"
+	"flag"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func testMessage(body *Part) *Message {
+	return &Message{
+		From:      &mail.Address{Name: "MChat User", Address: "user@example.com"},
+		To:        []*mail.Address{{Address: "friend@example.com"}},
+		Subject:   "Hello",
+		Date:      time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		MessageId: "<1@example.com>",
+		Body:      body,
+	}
+}
+
+func TestMessageGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  func() *Message
+	}{
+		{"plain", func() *Message {
+			return testMessage(TextPart("Knock Knock!\nWho's There?"))
+		}},
+		{"reply", func() *Message {
+			m := testMessage(TextPart("Who's There?"))
+			m.Subject = "Re: Hello"
+			m.MessageId = "<3@example.com>"
+			m.InReplyTo = "<2.1136214245@mail.example.com>"
+			m.References = []string{
+				"<1.1136214100@mail.example.com>",
+				"<CAFm4zq7Xb2RrW+iLQ@mail.example.com>",
+				"<2.1136214245@mail.example.com>",
+			}
+			return m
+		}},
+		{"encoded_words", func() *Message {
+			m := testMessage(TextPart("Zażółć gęślą jaźń"))
+			m.From.Name = "Łukasz Żółw"
+			m.To[0].Name = "Jürgen"
+			m.Subject = "Grüße aus Kraków, a rather long subject that has to be folded over lines"
+			return m
+		}},
+		{"long_line", func() *Message {
+			return testMessage(TextPart(strings.Repeat("All work and no play makes Jack a dull boy. ", 4)))
+		}},
+		{"flowed", func() *Message {
+			return testMessage(FlowedTextPart(strings.Repeat("All work and no play makes Jack a dull boy. ", 4) + "\n>not a quote"))
+		}},
+		{"calendar", func() *Message {
+			ics := "BEGIN:VCALENDAR\r\nMETHOD:REPLY\r\nBEGIN:VEVENT\r\nUID:1@example.com\r\n" +
+				"ATTENDEE;PARTSTAT=ACCEPTED:mailto:user@example.com\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+			body := MultipartPart("alternative", TextPart("Accepted"), CalendarPart([]byte(ics), "REPLY"))
+			body.Boundary = "mchat-boundary"
+			return testMessage(body)
+		}},
+		{"encrypted", func() *Message {
+			armored := "-----BEGIN PGP MESSAGE-----\n\nwV4DAAAAAAAAAAASAQdAbm90IHJlYWxseSBlbmNyeXB0ZWQ=\n=AAAA\n-----END PGP MESSAGE-----\n"
+			body := EncryptedPart([]byte(armored))
+			body.Boundary = "mchat-boundary"
+			return testMessage(body)
+		}},
+		{"signed", func() *Message {
+			signed := MultipartPart("alternative", TextPart("Signed"), HTMLPart("<p>Signed</p>"))
+			signed.Boundary = "mchat-inner"
+			body := SignedPart(signed, "sha-256", SMIMESignaturePart([]byte("not really a signature")))
+			body.Boundary = "mchat-boundary"
+			return testMessage(body)
+		}},
+		{"attachment", func() *Message {
+			body := MultipartPart("mixed",
+				TextPart("See the attached file"),
+				AttachmentPart("notes.bin", "application/octet-stream", []byte(strings.Repeat("\x00\x01\x02", 40))),
+			)
+			body.Boundary = "mchat-boundary"
+			m := testMessage(body)
+			m.Header.Add("X-MChat-Id", m.MessageId)
+			return m
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg().Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("message mismatch\nexpected:\n%s\ngot:\n%s", expected, got)
+			}
+			for _, l := range strings.Split(string(got), "\r\n") {
+				if len(l) > 78 {
+					t.Errorf("line longer than 78 characters: %q", l)
+				}
+			}
+			if _, err := mail.ReadMessage(strings.NewReader(string(got))); err != nil {
+				t.Errorf("message not readable: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewMessageId(t *testing.T) {
+	id := NewMessageId("user@example.com")
+	if !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("unexpected message id %q", id)
+	}
+	if id == NewMessageId("user@example.com") {
+		t.Errorf("message ids are not unique")
+	}
+}
+
+func TestSignedPartKeepsContent(t *testing.T) {
+	signed := MultipartPart("alternative", TextPart("Zażółć"), HTMLPart("<p>Zażółć</p>"))
+	var b bytes.Buffer
+	if _, err := signed.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := testMessage(SignedPart(signed, "sha-256", SMIMESignaturePart([]byte{0x30}))).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(msg, b.Bytes()) {
+		t.Errorf("the signed part was written differently:\n%s", msg)
+	}
+}
+
+func TestHeaderLineBreaks(t *testing.T) {
+	// the subject of a reply comes from someone else's mail
+	m := testMessage(TextPart("Hi"))
+	m.Subject = "Re: hi\r\nContent-Type: text/html"
+	m.Header.Add("X-Note", "a\nBcc: someone@example.com")
+	b, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/html") {
+		t.Errorf("the subject added a Content-Type %q", ct)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("a field value added a Bcc %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != m.Subject {
+		t.Errorf("subject %q, want %q", subject, m.Subject)
+	}
+}
@@ -0,0 +1,107 @@
+// Package flowed implements the text/plain format=flowed encoding of RFC 3676.
+//
+// A flowed line ends with a space, telling the reader it continues on the
+// next line, so paragraphs can be reflowed to any width.
+package flowed
+
+import (
+	"strings"
+)
+
+// LineWidth is the width Encode wraps paragraphs at, as recommended by the RFC
+const LineWidth = 72
+
+const signature = "-- "
+
+// Decode joins flowed lines into paragraphs, delSp removes the space
+// ending each flowed line as requested by the delsp=yes parameter
+func Decode(text string, delSp bool) string {
+	var out []string
+	var paragraph strings.Builder
+	depth := -1
+
+	flush := func() {
+		if depth < 0 {
+			return
+		}
+		line := paragraph.String()
+		if depth > 0 {
+			line = strings.Repeat(">", depth) + " " + line
+		}
+		out = append(out, line)
+		paragraph.Reset()
+		depth = -1
+	}
+
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		d := 0
+		for d < len(line) && line[d] == '>' {
+			d++
+		}
+		line = line[d:]
+		// undo space-stuffing
+		line = strings.TrimPrefix(line, " ")
+
+		if depth >= 0 && d != depth {
+			// a quote depth change ends the paragraph, RFC 3676 4.5
+			flush()
+		}
+		depth = d
+
+		if line == signature || !strings.HasSuffix(line, " ") {
+			paragraph.WriteString(line)
+			flush()
+			continue
+		}
+		if delSp {
+			line = strings.TrimSuffix(line, " ")
+		}
+		paragraph.WriteString(line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// Encode wraps the lines of text at LineWidth with soft line breaks
+func Encode(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if line != signature {
+			// trailing spaces would turn a fixed line into a flowed one
+			line = strings.TrimRight(line, " ")
+		}
+		for _, l := range wrap(line) {
+			out = append(out, stuff(l))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrap splits a line at spaces, every part but the last keeps its trailing space
+func wrap(line string) []string {
+	var lines []string
+	for len(line) > LineWidth {
+		i := strings.LastIndex(line[:LineWidth], " ")
+		if i <= 0 {
+			// a word longer than the line, break after it
+			i = strings.Index(line, " ")
+			if i < 0 {
+				break
+			}
+		}
+		lines = append(lines, line[:i+1])
+		line = line[i+1:]
+	}
+	return append(lines, line)
+}
+
+// stuff adds a space before lines a reader could take for quotes or
+// flowed markers, RFC 3676 4.4
+func stuff(line string) string {
+	if strings.HasPrefix(line, " ") || strings.HasPrefix(line, ">") || strings.HasPrefix(line, "From ") {
+		return " " + line
+	}
+	return line
+}
@@ -0,0 +1,49 @@
+package flowed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		delSp    bool
+		expected string
+	}{
+		{"fixed lines", "one\r\ntwo\r\n", false, "one\ntwo"},
+		{"paragraph", "The quick brown \r\nfox jumps over \r\nthe lazy dog.\r\nNext", false, "The quick brown fox jumps over the lazy dog.\nNext"},
+		{"delsp", "Supercalifragi \r\nlisticexpialidocious", true, "Supercalifragilisticexpialidocious"},
+		{"space stuffing", " >not a quote\r\n From here", false, ">not a quote\nFrom here"},
+		{"quotes", "> Quoted \r\n> text\r\n>> Deeper \r\n>> quote\r\nReply", false, "> Quoted text\n>> Deeper quote\nReply"},
+		{"quote depth change", "> Flowed \r\nNot joined", false, "> Flowed \nNot joined"},
+		{"signature", "Bye\r\n-- \r\nJohn", false, "Bye\n-- \nJohn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Decode(tt.text, tt.delSp)
+			if result != tt.expected {
+				t.Errorf("expected %q got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	long := strings.Repeat("All work and no play makes Jack a dull boy. ", 4)
+	encoded := Encode(long + "\n>quote\nFrom me\n-- \nJack")
+	for _, l := range strings.Split(encoded, "\n") {
+		if len(l) > LineWidth {
+			t.Errorf("line longer than %d characters: %q", LineWidth, l)
+		}
+	}
+	if !strings.Contains(encoded, "\n >quote\n From me\n-- \nJack") {
+		t.Errorf("lines not stuffed: %q", encoded)
+	}
+	decoded := Decode(encoded, false)
+	expected := strings.TrimRight(long, " ") + "\n>quote\nFrom me\n-- \nJack"
+	if decoded != expected {
+		t.Errorf("round trip failed, expected %q got %q", expected, decoded)
+	}
+}
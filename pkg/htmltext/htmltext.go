@@ -0,0 +1,301 @@
+// Package htmltext renders HTML mail bodies as readable terminal text.
+//
+// Paragraphs, lists, tables and blockquotes keep their structure, links
+// become numbered footnotes, and scripts, styles and images are dropped.
+package htmltext
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// nbsp marks whitespace that must survive the final cleanup,
+// used for indentation, table padding and preformatted text
+const nbsp = "\u00a0"
+
+type renderer struct {
+	links []string
+}
+
+func Render(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	rd := &renderer{}
+	text := clean(rd.render(doc))
+	if len(rd.links) > 0 {
+		var notes []string
+		for i, l := range rd.links {
+			notes = append(notes, fmt.Sprintf("[%d] %s", i+1, l))
+		}
+		text += "\n\n" + strings.Join(notes, "\n")
+	}
+	return strings.ReplaceAll(text, nbsp, " "), nil
+}
+
+func RenderString(s string) (string, error) {
+	return Render(strings.NewReader(s))
+}
+
+func (r *renderer) children(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(r.render(c))
+	}
+	return b.String()
+}
+
+func block(s string) string {
+	return "\n\n" + s + "\n\n"
+}
+
+func (r *renderer) render(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseSpace(n.Data)
+	case html.DocumentNode:
+		return r.children(n)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title, atom.Img, atom.Noscript,
+		atom.Template, atom.Svg, atom.Object, atom.Iframe:
+		return ""
+	case atom.Br:
+		return "\n"
+	case atom.Hr:
+		return block(strings.Repeat("─", 20))
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Center, atom.Address, atom.Dl, atom.Dt, atom.Form, atom.Main, atom.Nav,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return block(r.children(n))
+	case atom.Dd:
+		return block(indent(clean(r.children(n)), nbsp+nbsp, nbsp+nbsp))
+	case atom.Blockquote:
+		return block(indent(clean(r.children(n)), ">"+nbsp, ">"+nbsp))
+	case atom.Pre:
+		return block(preformatted(n))
+	case atom.Ul, atom.Ol:
+		return block(r.list(n))
+	case atom.Li:
+		// a list item outside of a list
+		return block("•" + nbsp + clean(r.children(n)))
+	case atom.Table:
+		return block(r.table(n))
+	case atom.A:
+		return r.link(n)
+	}
+	return r.children(n)
+}
+
+func (r *renderer) link(n *html.Node) string {
+	text := r.children(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return text
+	}
+	label := strings.TrimSpace(text)
+	if label == "" || label == href || "mailto:"+label == href {
+		return text
+	}
+	r.links = append(r.links, href)
+	return fmt.Sprintf("%s[%d]", text, len(r.links))
+}
+
+func (r *renderer) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		bullet := "•" + nbsp
+		if ordered {
+			bullet = strconv.Itoa(number) + "." + nbsp
+			number++
+		}
+		rest := strings.Repeat(nbsp, utf8.RuneCountInString(bullet))
+		items = append(items, indent(clean(r.children(c)), bullet, rest))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (r *renderer) table(n *html.Node) string {
+	var rows [][]string
+	columns := 0
+	layout := false
+	for _, tr := range findAll(n, atom.Tr) {
+		var row []string
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			if len(findAll(c, atom.Table)) > 0 {
+				layout = true
+			}
+			row = append(row, clean(r.children(c)))
+		}
+		columns = max(columns, len(row))
+		rows = append(rows, row)
+	}
+
+	// tables used for the layout of the mail are rendered as plain blocks
+	if layout || columns <= 1 {
+		var blocks []string
+		for _, row := range rows {
+			for _, cell := range row {
+				if cell != "" {
+					blocks = append(blocks, cell)
+				}
+			}
+		}
+		return strings.Join(blocks, "\n\n")
+	}
+
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "\n", " ")
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, columns)
+		for i := range cells {
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			if i < columns-1 {
+				cell += strings.Repeat(nbsp, widths[i]-utf8.RuneCountInString(cell))
+			}
+			cells[i] = cell
+		}
+		lines = append(lines, strings.Join(cells, nbsp+"│"+nbsp))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findAll returns the descendants of n with the given tag, without
+// descending into nested tables
+func findAll(n *html.Node, a atom.Atom) []*html.Node {
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.DataAtom == a {
+			found = append(found, c)
+			continue
+		}
+		if c.DataAtom != atom.Table {
+			found = append(found, findAll(c, a)...)
+		}
+	}
+	return found
+}
+
+func preformatted(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	text := strings.Trim(b.String(), "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	return strings.ReplaceAll(text, " ", nbsp)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapseSpace collapses runs of whitespace into a single space, keeping
+// a leading and a trailing one so words of adjacent nodes stay apart
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(fields, " ")
+	if isSpace(s[0]) {
+		out = " " + out
+	}
+	if isSpace(s[len(s)-1]) {
+		out += " "
+	}
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// indent prefixes the first line with first and the others with rest
+func indent(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + l
+		case l == "":
+			lines[i] = strings.TrimRight(rest, nbsp)
+		default:
+			lines[i] = rest + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clean trims the lines and squeezes consecutive empty lines
+func clean(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	empty := true
+	for _, l := range lines {
+		l = strings.Trim(l, " \t\r")
+		if l == "" {
+			if !empty {
+				out = append(out, "")
+			}
+			empty = true
+			continue
+		}
+		out = append(out, l)
+		empty = false
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
@@ -0,0 +1,64 @@
+package htmltext
+
+import "testing"
+
+func TestRenderString(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "paragraphs",
+			html:     "<p>Hello   there,</p><p>General\n Kenobi<br>You are a bold one</p>",
+			expected: "Hello there,\n\nGeneral Kenobi\nYou are a bold one",
+		},
+		{
+			name:     "dropped elements",
+			html:     `<html><head><title>T</title><style>p{}</style></head><body><script>alert(1)</script><img src="http://x/y.png">Text</body></html>`,
+			expected: "Text",
+		},
+		{
+			name:     "links",
+			html:     `<p>See <a href="https://example.com/a">the docs</a> or <a href="https://example.com">https://example.com</a></p>`,
+			expected: "See the docs[1] or https://example.com\n\n[1] https://example.com/a",
+		},
+		{
+			name:     "lists",
+			html:     `<ul><li>one</li><li>two<ol start="3"><li>three</li></ol></li></ul>`,
+			expected: "• one\n• two\n\n  3. three",
+		},
+		{
+			name:     "blockquote",
+			html:     `<p>Yes</p><blockquote><p>Are you</p><p>there?</p></blockquote>`,
+			expected: "Yes\n\n> Are you\n>\n> there?",
+		},
+		{
+			name:     "table",
+			html:     `<table><tr><th>Item</th><th>Price</th></tr><tr><td>Coffee</td><td>3 €</td></tr></table>`,
+			expected: "Item   │ Price\nCoffee │ 3 €",
+		},
+		{
+			name:     "layout table",
+			html:     `<table><tr><td><table><tr><td>Inner</td></tr></table></td></tr><tr><td>Footer</td></tr></table>`,
+			expected: "Inner\n\nFooter",
+		},
+		{
+			name:     "preformatted",
+			html:     "<pre>func main() {\n    fmt.Println(1)\n}</pre>",
+			expected: "func main() {\n    fmt.Println(1)\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RenderString(tt.html)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q got %q", tt.expected, result)
+			}
+		})
+	}
+}
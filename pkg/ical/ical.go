@@ -0,0 +1,679 @@
+// Package ical reads and writes the iCalendar events of RFC 5545 exchanged
+// by the iTIP scheduling methods of RFC 5546 (invitations and their replies).
+//
+// Only the VEVENT properties needed to show and answer an invitation are
+// kept. Times are converted to time.Time, floating and unknown time zones
+// are read in the local zone.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iTIP methods
+const (
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+	MethodReply   = "REPLY"
+)
+
+// Participation status of an attendee
+const (
+	PartStatNeedsAction = "NEEDS-ACTION"
+	PartStatAccepted    = "ACCEPTED"
+	PartStatTentative   = "TENTATIVE"
+	PartStatDeclined    = "DECLINED"
+)
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405"
+	// lineLength is the longest content line in octets, RFC 5545 3.1
+	lineLength = 75
+)
+
+type Calendar struct {
+	ProdId string
+	Method string
+	Events []*Event
+}
+
+type Event struct {
+	UID      string
+	Sequence int
+	// RecurrenceId identifies a single occurrence of a recurring event
+	RecurrenceId time.Time
+	Stamp        time.Time
+	Start        time.Time
+	End          time.Time
+	// AllDay events have dates without a time, End is exclusive
+	AllDay      bool
+	Summary     string
+	Description string
+	Location    string
+	// Status is TENTATIVE, CONFIRMED or CANCELLED
+	Status    string
+	Organizer *Attendee
+	Attendees []*Attendee
+}
+
+type Attendee struct {
+	Name  string
+	Email string
+	// PartStat is the participation status, PartStatNeedsAction when missing
+	PartStat string
+	Role     string
+	RSVP     bool
+}
+
+// property is a content line: NAME;PARAM=value:VALUE
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// component is a BEGIN/END block with its properties and sub-components
+type component struct {
+	name       string
+	props      []*property
+	components []*component
+}
+
+func (c *component) prop(name string) *property {
+	for _, p := range c.props {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c *component) text(name string) string {
+	if p := c.prop(name); p != nil {
+		return unescapeText(p.value)
+	}
+	return ""
+}
+
+// Parse reads the first VCALENDAR object of r
+func Parse(r io.Reader) (*Calendar, error) {
+	root, err := parseComponents(r)
+	if err != nil {
+		return nil, err
+	}
+	var vcal *component
+	for _, c := range root.components {
+		if c.name == "VCALENDAR" {
+			vcal = c
+			break
+		}
+	}
+	if vcal == nil {
+		return nil, fmt.Errorf("ical: no VCALENDAR object")
+	}
+
+	zones := make(map[string]*component)
+	for _, c := range vcal.components {
+		if c.name == "VTIMEZONE" {
+			zones[c.text("TZID")] = c
+		}
+	}
+
+	cal := &Calendar{
+		ProdId: vcal.text("PRODID"),
+		Method: strings.ToUpper(vcal.text("METHOD")),
+	}
+	for _, c := range vcal.components {
+		if c.name != "VEVENT" {
+			continue
+		}
+		e, err := parseEvent(c, zones)
+		if err != nil {
+			return nil, err
+		}
+		cal.Events = append(cal.Events, e)
+	}
+	return cal, nil
+}
+
+func parseEvent(c *component, zones map[string]*component) (*Event, error) {
+	e := &Event{
+		UID:         c.text("UID"),
+		Summary:     c.text("SUMMARY"),
+		Description: c.text("DESCRIPTION"),
+		Location:    c.text("LOCATION"),
+		Status:      strings.ToUpper(c.text("STATUS")),
+	}
+	if e.UID == "" {
+		return nil, fmt.Errorf("ical: event without UID")
+	}
+	if p := c.prop("SEQUENCE"); p != nil {
+		e.Sequence, _ = strconv.Atoi(strings.TrimSpace(p.value))
+	}
+
+	var err error
+	if p := c.prop("DTSTART"); p != nil {
+		e.Start, e.AllDay, err = parseTime(p, zones)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p := c.prop("DTEND"); p != nil {
+		e.End, _, err = parseTime(p, zones)
+		if err != nil {
+			return nil, err
+		}
+	} else if p := c.prop("DURATION"); p != nil {
+		d, err := parseDuration(p.value)
+		if err != nil {
+			return nil, err
+		}
+		e.End = e.Start.Add(d)
+	} else if e.AllDay {
+		e.End = e.Start.AddDate(0, 0, 1)
+	} else {
+		e.End = e.Start
+	}
+	if p := c.prop("DTSTAMP"); p != nil {
+		e.Stamp, _, _ = parseTime(p, zones)
+	}
+	if p := c.prop("RECURRENCE-ID"); p != nil {
+		e.RecurrenceId, _, err = parseTime(p, zones)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p := c.prop("ORGANIZER"); p != nil {
+		e.Organizer = parseAttendee(p)
+	}
+	for _, p := range c.props {
+		if p.name == "ATTENDEE" {
+			e.Attendees = append(e.Attendees, parseAttendee(p))
+		}
+	}
+	return e, nil
+}
+
+func parseAttendee(p *property) *Attendee {
+	a := &Attendee{
+		Name:     p.params["CN"],
+		Email:    calAddress(p.value),
+		PartStat: strings.ToUpper(p.params["PARTSTAT"]),
+		Role:     strings.ToUpper(p.params["ROLE"]),
+		RSVP:     strings.EqualFold(p.params["RSVP"], "TRUE"),
+	}
+	if a.PartStat == "" {
+		a.PartStat = PartStatNeedsAction
+	}
+	return a
+}
+
+// calAddress strips the mailto scheme of a CAL-ADDRESS value
+func calAddress(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 7 && strings.EqualFold(v[:7], "mailto:") {
+		return v[7:]
+	}
+	return v
+}
+
+// parseTime parses a DATE or DATE-TIME value, the boolean reports a DATE
+func parseTime(p *property, zones map[string]*component) (time.Time, bool, error) {
+	v := strings.TrimSpace(p.value)
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(v) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, v, time.Local)
+		return t, true, err
+	}
+	if utc, ok := strings.CutSuffix(v, "Z"); ok {
+		t, err := time.ParseInLocation(dateTimeFormat, utc, time.UTC)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, v, time.Local)
+	if err != nil {
+		return t, false, err
+	}
+	if tzid := strings.Trim(p.params["TZID"], "/"); tzid != "" {
+		t = inZone(t, tzid, zones[p.params["TZID"]])
+	}
+	return t, false, nil
+}
+
+// inZone moves the wall clock time t into the zone tzid, falling back to
+// the offsets of the VTIMEZONE definition for non IANA names (Outlook)
+func inZone(t time.Time, tzid string, vtz *component) time.Time {
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return wallClock(t, loc)
+	}
+	if vtz == nil {
+		return t
+	}
+	offset, ok := zoneOffset(t, vtz)
+	if !ok {
+		return t
+	}
+	return wallClock(t, time.FixedZone(tzid, offset))
+}
+
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// zoneOffset finds the STANDARD or DAYLIGHT observance in effect at the
+// wall clock time t, the observances recur yearly as Outlook and Google
+// define them
+func zoneOffset(t time.Time, vtz *component) (int, bool) {
+	var best time.Time
+	offset, found := 0, false
+	for _, obs := range vtz.components {
+		if obs.name != "STANDARD" && obs.name != "DAYLIGHT" {
+			continue
+		}
+		p := obs.prop("TZOFFSETTO")
+		if p == nil {
+			continue
+		}
+		off, err := parseOffset(p.value)
+		if err != nil {
+			continue
+		}
+		start := obs.prop("DTSTART")
+		if start == nil {
+			continue
+		}
+		onset, err := time.ParseInLocation(dateTimeFormat, strings.TrimSpace(start.value), time.UTC)
+		if err != nil {
+			continue
+		}
+		// the latest onset not after t, this year or the last one
+		for _, year := range []int{t.Year(), t.Year() - 1} {
+			at := onset
+			if rule := obs.prop("RRULE"); rule != nil {
+				at = yearlyOnset(rule.value, onset, year)
+			}
+			if at.IsZero() || at.After(wallClock(t, time.UTC)) {
+				continue
+			}
+			if !found || at.After(best) {
+				best, offset, found = at, off, true
+			}
+			break
+		}
+	}
+	return offset, found
+}
+
+// yearlyOnset returns the occurrence in year of a yearly RRULE such as
+// FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU, or the zero time if unsupported
+func yearlyOnset(rule string, onset time.Time, year int) time.Time {
+	parts := make(map[string]string)
+	for _, kv := range strings.Split(rule, ";") {
+		k, v, _ := strings.Cut(kv, "=")
+		parts[strings.ToUpper(k)] = strings.ToUpper(v)
+	}
+	if parts["FREQ"] != "YEARLY" {
+		return time.Time{}
+	}
+	month := onset.Month()
+	if m, err := strconv.Atoi(parts["BYMONTH"]); err == nil {
+		month = time.Month(m)
+	}
+	day := onset.Day()
+	if byDay := parts["BYDAY"]; len(byDay) > 2 {
+		n, err := strconv.Atoi(byDay[:len(byDay)-2])
+		weekday, ok := weekdays[byDay[len(byDay)-2:]]
+		if err != nil || !ok {
+			return time.Time{}
+		}
+		day = nthWeekday(year, month, weekday, n)
+	}
+	return time.Date(year, month, day, onset.Hour(), onset.Minute(), onset.Second(), 0, time.UTC)
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// nthWeekday returns the day of the n-th weekday of the month, counting
+// from the end of the month when n is negative
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) int {
+	if n > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return 1 + (int(weekday)-int(first.Weekday())+7)%7 + (n-1)*7
+	}
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	return last.Day() - (int(last.Weekday())-int(weekday)+7)%7 + (n+1)*7
+}
+
+// parseOffset parses a UTC offset such as +0200 or -053000
+func parseOffset(v string) (int, error) {
+	v = strings.TrimSpace(v)
+	if len(v) != 5 && len(v) != 7 {
+		return 0, fmt.Errorf("ical: invalid utc offset %q", v)
+	}
+	sign := 1
+	switch v[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("ical: invalid utc offset %q", v)
+	}
+	secs := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(v) {
+			break
+		}
+		n, err := strconv.Atoi(v[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("ical: invalid utc offset %q", v)
+		}
+		secs += n * unit
+	}
+	return sign * secs, nil
+}
+
+// parseDuration parses a DURATION value such as PT1H30M, P1D or -P1W
+func parseDuration(v string) (time.Duration, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	sign := time.Duration(1)
+	if rest, ok := strings.CutPrefix(v, "-"); ok {
+		sign, v = -1, rest
+	}
+	v = strings.TrimPrefix(v, "+")
+	rest, ok := strings.CutPrefix(v, "P")
+	if !ok {
+		return 0, fmt.Errorf("ical: invalid duration %q", v)
+	}
+
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, r := range rest {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("ical: invalid duration %q", v)
+		}
+		num = ""
+		var unit time.Duration
+		switch {
+		case !inTime && r == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && r == 'D':
+			unit = 24 * time.Hour
+		case inTime && r == 'H':
+			unit = time.Hour
+		case inTime && r == 'M':
+			unit = time.Minute
+		case inTime && r == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("ical: invalid duration %q", v)
+		}
+		d += time.Duration(n) * unit
+	}
+	if num != "" {
+		return 0, fmt.Errorf("ical: invalid duration %q", v)
+	}
+	return sign * d, nil
+}
+
+// parseComponents unfolds the content lines of r and nests them into
+// components under an unnamed root
+func parseComponents(r io.Reader) (*component, error) {
+	root := &component{}
+	stack := []*component{root}
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch p.name {
+		case "BEGIN":
+			c := &component{name: strings.ToUpper(p.value)}
+			top.components = append(top.components, c)
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 1 || top.name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("ical: unexpected END:%s", p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.props = append(top.props, p)
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("ical: %s is not closed", stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+// unfold joins the lines continued with a leading space or tab
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, s.Err()
+}
+
+func parseProperty(line string) (*property, error) {
+	p := &property{params: make(map[string]string)}
+
+	// the name ends at the first ; or :
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("ical: invalid content line %q", line)
+	}
+	p.name = strings.ToUpper(line[:i])
+	rest := line[i:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("ical: invalid parameter in %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("ical: unterminated parameter in %q", line)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return nil, fmt.Errorf("ical: invalid content line %q", line)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		// a list value such as MEMBER keeps its commas
+		p.params[name] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return nil, fmt.Errorf("ical: invalid content line %q", line)
+	}
+	p.value = rest[1:]
+	return p, nil
+}
+
+func unescapeText(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' || i+1 == len(v) {
+			b.WriteByte(v[i])
+			continue
+		}
+		i++
+		switch v[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
+
+func escapeText(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(v)
+}
+
+// WriteTo writes the calendar with CRLF line endings and folded lines
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	writeLine(&b, "BEGIN", nil, "VCALENDAR")
+	writeLine(&b, "PRODID", nil, escapeText(c.ProdId))
+	writeLine(&b, "VERSION", nil, "2.0")
+	writeLine(&b, "CALSCALE", nil, "GREGORIAN")
+	if c.Method != "" {
+		writeLine(&b, "METHOD", nil, c.Method)
+	}
+	for _, e := range c.Events {
+		e.write(&b)
+	}
+	writeLine(&b, "END", nil, "VCALENDAR")
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+func (c *Calendar) Bytes() []byte {
+	var b bytes.Buffer
+	c.WriteTo(&b)
+	return b.Bytes()
+}
+
+func (e *Event) write(b *bytes.Buffer) {
+	writeLine(b, "BEGIN", nil, "VEVENT")
+	writeLine(b, "UID", nil, escapeText(e.UID))
+	if e.Sequence > 0 {
+		writeLine(b, "SEQUENCE", nil, strconv.Itoa(e.Sequence))
+	}
+	stamp := e.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	writeLine(b, "DTSTAMP", nil, formatUTC(stamp))
+	if !e.RecurrenceId.IsZero() {
+		writeLine(b, "RECURRENCE-ID", nil, formatUTC(e.RecurrenceId))
+	}
+	if !e.Start.IsZero() {
+		if e.AllDay {
+			writeLine(b, "DTSTART", [][2]string{{"VALUE", "DATE"}}, e.Start.Format(dateFormat))
+			writeLine(b, "DTEND", [][2]string{{"VALUE", "DATE"}}, e.End.Format(dateFormat))
+		} else {
+			writeLine(b, "DTSTART", nil, formatUTC(e.Start))
+			writeLine(b, "DTEND", nil, formatUTC(e.End))
+		}
+	}
+	if e.Summary != "" {
+		writeLine(b, "SUMMARY", nil, escapeText(e.Summary))
+	}
+	if e.Location != "" {
+		writeLine(b, "LOCATION", nil, escapeText(e.Location))
+	}
+	if e.Description != "" {
+		writeLine(b, "DESCRIPTION", nil, escapeText(e.Description))
+	}
+	if e.Status != "" {
+		writeLine(b, "STATUS", nil, e.Status)
+	}
+	if e.Organizer != nil {
+		writeLine(b, "ORGANIZER", attendeeParams(e.Organizer, false), "mailto:"+e.Organizer.Email)
+	}
+	for _, a := range e.Attendees {
+		writeLine(b, "ATTENDEE", attendeeParams(a, true), "mailto:"+a.Email)
+	}
+	writeLine(b, "END", nil, "VEVENT")
+}
+
+func attendeeParams(a *Attendee, attendee bool) [][2]string {
+	var params [][2]string
+	if a.Name != "" {
+		params = append(params, [2]string{"CN", a.Name})
+	}
+	if attendee {
+		if a.Role != "" {
+			params = append(params, [2]string{"ROLE", a.Role})
+		}
+		if a.PartStat != "" {
+			params = append(params, [2]string{"PARTSTAT", a.PartStat})
+		}
+		if a.RSVP {
+			params = append(params, [2]string{"RSVP", "TRUE"})
+		}
+	}
+	return params
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat) + "Z"
+}
+
+// writeLine writes a content line folded at lineLength octets without
+// splitting UTF-8 sequences
+func writeLine(b *bytes.Buffer, name string, params [][2]string, value string) {
+	var line strings.Builder
+	line.WriteString(name)
+	for _, p := range params {
+		line.WriteString(";" + p[0] + "=")
+		v := strings.ReplaceAll(p[1], `"`, "'")
+		if strings.ContainsAny(v, ";:, ") {
+			v = `"` + v + `"`
+		}
+		line.WriteString(v)
+	}
+	line.WriteString(":" + value)
+
+	s := line.String()
+	limit := lineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation counts
+		limit = lineLength - 1
+	}
+	b.WriteString(s + "\r\n")
+}
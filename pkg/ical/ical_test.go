@@ -0,0 +1,181 @@
+package ical
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFile(t *testing.T, name string) *Calendar {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cal, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(cal.Events))
+	}
+	return cal
+}
+
+func TestParseGoogleRequest(t *testing.T) {
+	cal := parseFile(t, "google_request.ics")
+	e := cal.Events[0]
+
+	if cal.Method != MethodRequest {
+		t.Errorf("Method = %q", cal.Method)
+	}
+	if e.Summary != "Release sync" || e.Location != "Room 4, 2nd floor" {
+		t.Errorf("Summary, Location = %q, %q", e.Summary, e.Location)
+	}
+	if want := "Agenda:\n- release plan\n- on-call rota, again"; e.Description != want {
+		t.Errorf("Description = %q, want %q", e.Description, want)
+	}
+	wantStart := time.Date(2025, 10, 21, 13, 0, 0, 0, time.UTC)
+	if !e.Start.Equal(wantStart) || e.End.Sub(e.Start) != time.Hour {
+		t.Errorf("Start, End = %v, %v", e.Start, e.End)
+	}
+	if e.Organizer == nil || e.Organizer.Name != "Anna Kowalska" || e.Organizer.Email != "anna@example.com" {
+		t.Errorf("Organizer = %+v", e.Organizer)
+	}
+	if len(e.Attendees) != 2 {
+		t.Fatalf("got %d attendees", len(e.Attendees))
+	}
+	bob := e.Attendees[1]
+	if bob.Email != "bob@example.org" || bob.PartStat != PartStatNeedsAction || !bob.RSVP {
+		t.Errorf("Attendee = %+v", bob)
+	}
+}
+
+func TestParseOutlookRequest(t *testing.T) {
+	e := parseFile(t, "outlook_request.ics").Events[0]
+
+	// W. Europe Standard Time is not an IANA name, the VTIMEZONE gives +01:00
+	wantStart := time.Date(2026, 1, 15, 8, 30, 0, 0, time.UTC)
+	if !e.Start.Equal(wantStart) {
+		t.Errorf("Start = %v, want %v", e.Start, wantStart)
+	}
+	if e.Organizer == nil || e.Organizer.Name != "Müller, Jonas" {
+		t.Errorf("Organizer = %+v", e.Organizer)
+	}
+	if e.Sequence != 2 || !strings.HasSuffix(e.UID, "1A2B3C4D5E6F") {
+		t.Errorf("Sequence, UID = %d, %q", e.Sequence, e.UID)
+	}
+	if len(e.Attendees) != 1 || e.Attendees[0].Email != "bob@example.org" {
+		t.Errorf("Attendees = %+v", e.Attendees)
+	}
+}
+
+func TestParseCancelAllDay(t *testing.T) {
+	cal := parseFile(t, "google_cancel_allday.ics")
+	e := cal.Events[0]
+	if cal.Method != MethodCancel || e.Status != "CANCELLED" {
+		t.Errorf("Method, Status = %q, %q", cal.Method, e.Status)
+	}
+	if !e.AllDay || e.Start.Day() != 24 || e.End.Day() != 27 {
+		t.Errorf("AllDay, Start, End = %v, %v, %v", e.AllDay, e.Start, e.End)
+	}
+}
+
+func TestZoneOffset(t *testing.T) {
+	root, err := parseComponents(strings.NewReader(readFile(t, "outlook_request.ics")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := root.components[0].components[0]
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), 3600},
+		{time.Date(2026, 3, 29, 1, 59, 0, 0, time.UTC), 3600},
+		{time.Date(2026, 3, 29, 2, 0, 0, 0, time.UTC), 7200},
+		{time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC), 7200},
+		{time.Date(2026, 10, 25, 3, 0, 0, 0, time.UTC), 3600},
+	}
+	for _, tt := range tests {
+		got, ok := zoneOffset(tt.at, zone)
+		if !ok || got != tt.want {
+			t.Errorf("zoneOffset(%v) = %d, %v, want %d", tt.at, got, ok, tt.want)
+		}
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"-P0DT0H10M0S", -10 * time.Minute},
+		{"P1DT12H", 36 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"1H", "PT1X", "P1H", "PT5"} {
+		if _, err := parseDuration(in); err == nil {
+			t.Errorf("parseDuration(%q) should fail", in)
+		}
+	}
+}
+
+func TestReplyRoundTrip(t *testing.T) {
+	req := parseFile(t, "outlook_request.ics").Events[0]
+	reply := &Calendar{
+		ProdId: "-//mchat//EN",
+		Method: MethodReply,
+		Events: []*Event{{
+			UID:       req.UID,
+			Sequence:  req.Sequence,
+			Start:     req.Start,
+			End:       req.End,
+			Summary:   req.Summary,
+			Organizer: req.Organizer,
+			Attendees: []*Attendee{{Name: "Bob", Email: "bob@example.org", PartStat: PartStatAccepted}},
+		}},
+	}
+	b := reply.Bytes()
+	for _, line := range strings.Split(string(b), "\r\n") {
+		if len(line) > lineLength {
+			t.Errorf("line longer than %d octets: %q", lineLength, line)
+		}
+	}
+
+	got, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := got.Events[0]
+	if got.Method != MethodReply || e.UID != req.UID || e.Sequence != 2 || !e.Start.Equal(req.Start) {
+		t.Errorf("reply = %q %+v", got.Method, e)
+	}
+	if e.Organizer.Name != "Müller, Jonas" {
+		t.Errorf("Organizer = %+v", e.Organizer)
+	}
+	if len(e.Attendees) != 1 || e.Attendees[0].PartStat != PartStatAccepted {
+		t.Errorf("Attendees = %+v", e.Attendees)
+	}
+}
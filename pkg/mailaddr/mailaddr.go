@@ -0,0 +1,65 @@
+// Package mailaddr folds the spellings of an email address that reach the
+// same mailbox into one canonical form, so that Bob@Example.com and
+// bob@example.com are the same chat.
+package mailaddr
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// gmailDomains are the domains of Gmail, where dots in the local part are
+// ignored
+var gmailDomains = map[string]bool{"gmail.com": true, "googlemail.com": true}
+
+// Rules tell how the local part is folded, the domain is always lowercased
+// and converted to its ASCII (punycode) form
+type Rules struct {
+	// FoldLocalCase lowercases the local part, which almost every server
+	// treats case-insensitively
+	FoldLocalCase bool `json:"fold_local_case"`
+	// FoldPlus drops the +tag of sub-addresses, bob+work@ becomes bob@
+	FoldPlus bool `json:"fold_plus"`
+	// FoldGmailDots drops the dots of Gmail local parts and spells
+	// googlemail.com gmail.com
+	FoldGmailDots bool `json:"fold_gmail_dots"`
+}
+
+// DefaultRules only fold the case
+var DefaultRules = Rules{FoldLocalCase: true}
+
+// Normalize returns the canonical form of address, an address without
+// domain is only trimmed
+func (r Rules) Normalize(address string) string {
+	address = strings.TrimSpace(address)
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return address
+	}
+	local, domain := address[:at], Domain(address[at+1:])
+
+	if r.FoldLocalCase {
+		local = strings.ToLower(local)
+	}
+	if r.FoldPlus {
+		if i := strings.IndexByte(local, '+'); i > 0 {
+			local = local[:i]
+		}
+	}
+	if r.FoldGmailDots && gmailDomains[domain] {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// Domain returns the canonical form of a domain: lowercase ASCII, with the
+// internationalized labels in punycode
+func Domain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+	return domain
+}
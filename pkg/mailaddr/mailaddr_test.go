@@ -0,0 +1,30 @@
+package mailaddr
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	all := Rules{FoldLocalCase: true, FoldPlus: true, FoldGmailDots: true}
+	tests := []struct {
+		rules Rules
+		in    string
+		want  string
+	}{
+		{Rules{}, "Bob@Example.COM", "Bob@example.com"},
+		{DefaultRules, "Bob@Example.COM", "bob@example.com"},
+		{DefaultRules, " bob+work@example.com ", "bob+work@example.com"},
+		{all, "Bob+Work@Example.com", "bob@example.com"},
+		{all, "+tag@example.com", "+tag@example.com"},
+		{all, "Bob.Smith+news@GoogleMail.com", "bobsmith@gmail.com"},
+		{DefaultRules, "bob.smith@gmail.com", "bob.smith@gmail.com"},
+		{all, "bob.smith@example.com", "bob.smith@example.com"},
+		{DefaultRules, "anna@Bücher.example", "anna@xn--bcher-kva.example"},
+		{DefaultRules, "anna@example.com.", "anna@example.com"},
+		{DefaultRules, `"a@b"@example.com`, `"a@b"@example.com`},
+		{DefaultRules, "list:go.example.org", "list:go.example.org"},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Normalize(tt.in); got != tt.want {
+			t.Errorf("%+v.Normalize(%q) = %q, want %q", tt.rules, tt.in, got, tt.want)
+		}
+	}
+}
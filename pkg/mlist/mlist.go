@@ -0,0 +1,143 @@
+// Package mlist reads the headers mailing lists add to the messages they
+// distribute: List-Id of RFC 2919, List-Post and List-Unsubscribe of
+// RFC 2369, and the one-click unsubscription of RFC 8058.
+package mlist
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// OneClickBody is the form posted to the List-Unsubscribe HTTPS URI to
+// unsubscribe with one click
+const OneClickBody = "List-Unsubscribe=One-Click"
+
+// List describes a mailing list from the headers of one of its messages
+type List struct {
+	// Id identifies the list, such as golang-nuts.googlegroups.com
+	Id string
+	// Name is the description of the List-Id header
+	Name string
+	// Post is the address of the list, empty when it doesn't accept posts
+	Post string
+	// Unsubscribe holds the mailto and HTTPS URIs in order of preference
+	Unsubscribe []string
+	// OneClick tells that the HTTPS URIs unsubscribe with a POST request
+	OneClick bool
+}
+
+// Header is implemented by mail.Header and textproto.MIMEHeader
+type Header interface {
+	Get(key string) string
+}
+
+// Parse returns the list a message was distributed by, or nil when it has
+// no List-Id
+func Parse(h Header) *List {
+	id, name, err := ParseListId(h.Get("List-Id"))
+	if err != nil {
+		return nil
+	}
+	l := &List{Id: id, Name: name, Unsubscribe: ParseURIs(h.Get("List-Unsubscribe"))}
+	for _, uri := range ParseURIs(h.Get("List-Post")) {
+		if m, err := ParseMailto(uri); err == nil {
+			l.Post = m.Address
+			break
+		}
+	}
+	l.OneClick = strings.EqualFold(strings.TrimSpace(h.Get("List-Unsubscribe-Post")), OneClickBody)
+	return l
+}
+
+// ParseListId parses a List-Id header, "Description <id>" or only the id
+func ParseListId(value string) (id, name string, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", errors.New("mlist: no List-Id")
+	}
+	start, end := strings.LastIndexByte(value, '<'), strings.LastIndexByte(value, '>')
+	if start < 0 || end < start {
+		return strings.ToLower(value), "", nil
+	}
+	id = strings.ToLower(strings.TrimSpace(value[start+1 : end]))
+	if id == "" {
+		return "", "", errors.New("mlist: empty List-Id")
+	}
+	name = strings.Trim(strings.TrimSpace(value[:start]), `"`)
+	if decoded, err := new(mail.AddressParser).Parse(value[:start] + "<a@b>"); err == nil && decoded.Name != "" {
+		// the description may be an encoded word
+		name = decoded.Name
+	}
+	return id, name, nil
+}
+
+// ParseURIs returns the URIs in angle brackets of a List-* header, the
+// comments and the NO of a List-Post without address are left out
+func ParseURIs(value string) []string {
+	var uris []string
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			return uris
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			return uris
+		}
+		uri := strings.Join(strings.Fields(value[start+1:start+end]), "")
+		if uri != "" {
+			uris = append(uris, uri)
+		}
+		value = value[start+end+1:]
+	}
+}
+
+// Mailto is a mailto URI of RFC 6068
+type Mailto struct {
+	Address string
+	Subject string
+	Body    string
+}
+
+// ParseMailto parses a mailto URI
+func ParseMailto(uri string) (*Mailto, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, "mailto") || u.Opaque == "" {
+		return nil, errors.New("mlist: not a mailto URI: " + uri)
+	}
+	address, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	return &Mailto{Address: address, Subject: q.Get("subject"), Body: q.Get("body")}, nil
+}
+
+// OneClickURL returns the HTTPS URI to post OneClickBody to, or "" when the
+// list doesn't support one-click unsubscription
+func (l *List) OneClickURL() string {
+	if !l.OneClick {
+		return ""
+	}
+	for _, uri := range l.Unsubscribe {
+		if strings.HasPrefix(strings.ToLower(uri), "https:") {
+			return uri
+		}
+	}
+	return ""
+}
+
+// UnsubscribeMailto returns the first mailto unsubscription URI, or nil
+func (l *List) UnsubscribeMailto() *Mailto {
+	for _, uri := range l.Unsubscribe {
+		if m, err := ParseMailto(uri); err == nil {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package mlist
+
+import (
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   *List
+	}{
+		{
+			name: "google groups",
+			header: map[string]string{
+				"List-Id":               `<golang-nuts.googlegroups.com>`,
+				"List-Post":             `<https://groups.google.com/group/golang-nuts/post>, <mailto:golang-nuts@googlegroups.com>`,
+				"List-Unsubscribe":      "<mailto:googlegroups-manage+123+unsubscribe@googlegroups.com>,\r\n <https://groups.google.com/group/golang-nuts/subscribe>",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+			want: &List{
+				Id:   "golang-nuts.googlegroups.com",
+				Post: "golang-nuts@googlegroups.com",
+				Unsubscribe: []string{
+					"mailto:googlegroups-manage+123+unsubscribe@googlegroups.com",
+					"https://groups.google.com/group/golang-nuts/subscribe",
+				},
+				OneClick: true,
+			},
+		},
+		{
+			name: "announcement list",
+			header: map[string]string{
+				"List-Id":          `"Example News" <News.Example.COM>`,
+				"List-Post":        `NO (posting not allowed on this list)`,
+				"List-Unsubscribe": `<mailto:news-request@example.com?subject=unsubscribe> (Use this to leave)`,
+			},
+			want: &List{
+				Id:          "news.example.com",
+				Name:        "Example News",
+				Unsubscribe: []string{"mailto:news-request@example.com?subject=unsubscribe"},
+			},
+		},
+		{
+			name: "encoded description",
+			header: map[string]string{
+				"List-Id": `=?utf-8?q?Caf=C3=A9?= <cafe.lists.example.org>`,
+			},
+			want: &List{Id: "cafe.lists.example.org", Name: "Café"},
+		},
+		{
+			name:   "not a list",
+			header: map[string]string{"List-Unsubscribe": "<mailto:unsubscribe@shop.example>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := textproto.MIMEHeader{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			got := Parse(h)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMailto(t *testing.T) {
+	m, err := ParseMailto("mailto:list-request@example.com?subject=unsubscribe%20me&body=please")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Mailto{Address: "list-request@example.com", Subject: "unsubscribe me", Body: "please"}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %+v", m)
+	}
+	if _, err := ParseMailto("https://example.com/unsubscribe"); err == nil {
+		t.Error("an HTTPS URI isn't a mailto URI")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	l := &List{Unsubscribe: []string{"mailto:leave@example.com", "http://example.com/u", "https://example.com/u?id=1"}}
+	if got := l.OneClickURL(); got != "" {
+		t.Errorf("one-click URL without List-Unsubscribe-Post = %q", got)
+	}
+	l.OneClick = true
+	if got := l.OneClickURL(); got != "https://example.com/u?id=1" {
+		t.Errorf("one-click URL = %q", got)
+	}
+	if m := l.UnsubscribeMailto(); m == nil || m.Address != "leave@example.com" {
+		t.Errorf("mailto = %+v", m)
+	}
+}
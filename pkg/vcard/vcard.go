@@ -0,0 +1,280 @@
+// Package vcard reads the contact cards of RFC 6350 (vCard 4.0) and
+// RFC 2426 (vCard 3.0), and the 2.1 cards still sent by phones.
+//
+// Only the properties needed to add a contact are kept: the names, email
+// addresses, phone numbers and organization.
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Card struct {
+	Version string
+	// FormattedName is the display name, built from Name when FN is missing
+	FormattedName string
+	Name          Name
+	// Emails and Phones are sorted by preference
+	Emails       []string
+	Phones       []string
+	Organization string
+}
+
+// Name holds the components of the N property
+type Name struct {
+	Family     string
+	Given      string
+	Additional string
+	Prefix     string
+	Suffix     string
+}
+
+// property is a content line: group.NAME;PARAM=value:VALUE
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// preference ranks a property by its PREF parameter (4.0) or pref type
+// (3.0), lower is preferred
+func (p *property) preference() int {
+	if v := p.params["PREF"]; len(v) > 0 {
+		if n, err := strconv.Atoi(v[0]); err == nil {
+			return n
+		}
+	}
+	for _, t := range p.params["TYPE"] {
+		if strings.EqualFold(t, "pref") {
+			return 1
+		}
+	}
+	return 100
+}
+
+// Parse reads all the cards of r
+func Parse(r io.Reader) ([]*Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*Card
+	var props []*property
+	inCard := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			if inCard {
+				return nil, fmt.Errorf("vcard: nested BEGIN:VCARD")
+			}
+			inCard, props = true, nil
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if !inCard {
+				return nil, fmt.Errorf("vcard: unexpected END:VCARD")
+			}
+			inCard = false
+			cards = append(cards, newCard(props))
+		case inCard:
+			props = append(props, p)
+		}
+	}
+	if inCard {
+		return nil, fmt.Errorf("vcard: VCARD is not closed")
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("vcard: no VCARD object")
+	}
+	return cards, nil
+}
+
+func newCard(props []*property) *Card {
+	c := &Card{}
+	var emails, phones []*property
+	for _, p := range props {
+		switch p.name {
+		case "VERSION":
+			c.Version = p.value
+		case "FN":
+			if c.FormattedName == "" {
+				c.FormattedName = unescape(p.value)
+			}
+		case "N":
+			n := splitComponents(p.value)
+			for len(n) < 5 {
+				n = append(n, "")
+			}
+			c.Name = Name{Family: n[0], Given: n[1], Additional: n[2], Prefix: n[3], Suffix: n[4]}
+		case "EMAIL":
+			emails = append(emails, p)
+		case "TEL":
+			phones = append(phones, p)
+		case "ORG":
+			if c.Organization == "" {
+				// the organizational units follow the name
+				c.Organization = splitComponents(p.value)[0]
+			}
+		}
+	}
+
+	byPreference := func(a, b *property) int { return a.preference() - b.preference() }
+	slices.SortStableFunc(emails, byPreference)
+	slices.SortStableFunc(phones, byPreference)
+	for _, p := range emails {
+		if v := strings.TrimSpace(strings.TrimPrefix(unescape(p.value), "mailto:")); v != "" {
+			c.Emails = append(c.Emails, v)
+		}
+	}
+	for _, p := range phones {
+		if v := strings.TrimSpace(strings.TrimPrefix(unescape(p.value), "tel:")); v != "" {
+			c.Phones = append(c.Phones, v)
+		}
+	}
+
+	if c.FormattedName == "" {
+		var parts []string
+		for _, s := range []string{c.Name.Prefix, c.Name.Given, c.Name.Additional, c.Name.Family, c.Name.Suffix} {
+			if s != "" {
+				parts = append(parts, s)
+			}
+		}
+		c.FormattedName = strings.Join(parts, " ")
+	}
+	return c
+}
+
+// unfold joins the lines continued with a leading space or tab, and the
+// soft line breaks of quoted-printable 2.1 values
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	softBreak := false
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		switch {
+		case softBreak:
+			lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], "=") + line
+		case len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0:
+			lines[len(lines)-1] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+		last := lines[len(lines)-1]
+		softBreak = strings.HasSuffix(last, "=") && strings.Contains(strings.ToUpper(last), "QUOTED-PRINTABLE")
+	}
+	return lines, s.Err()
+}
+
+func parseProperty(line string) (*property, error) {
+	p := &property{params: make(map[string][]string)}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("vcard: invalid content line %q", line)
+	}
+	name := strings.ToUpper(line[:i])
+	// the group prefix, e.g. item1.EMAIL, only relates properties
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	p.name = name
+	rest := line[i:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		var param string
+		if end := indexUnquoted(rest, ";:"); end >= 0 {
+			param, rest = rest[:end], rest[end:]
+		} else {
+			return nil, fmt.Errorf("vcard: invalid content line %q", line)
+		}
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			// 2.1 bare types such as TEL;CELL;PREF
+			key, value = "TYPE", param
+		}
+		key = strings.ToUpper(key)
+		for _, v := range strings.Split(strings.Trim(value, `"`), ",") {
+			p.params[key] = append(p.params[key], v)
+		}
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return nil, fmt.Errorf("vcard: invalid content line %q", line)
+	}
+	p.value = rest[1:]
+
+	if enc := p.params["ENCODING"]; len(enc) > 0 && strings.EqualFold(enc[0], "QUOTED-PRINTABLE") {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(p.value)))
+		if err != nil {
+			return nil, fmt.Errorf("vcard: %w", err)
+		}
+		p.value = string(decoded)
+	}
+	return p, nil
+}
+
+// indexUnquoted is strings.IndexAny skipping double quoted sections
+func indexUnquoted(s, chars string) int {
+	quoted := false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && strings.ContainsRune(chars, r):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitComponents splits a structured value at the unescaped semicolons
+func splitComponents(v string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v):
+			b.WriteByte(v[i])
+			b.WriteByte(v[i+1])
+			i++
+		case v[i] == ';':
+			parts = append(parts, unescape(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return append(parts, unescape(b.String()))
+}
+
+func unescape(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' || i+1 == len(v) {
+			b.WriteByte(v[i])
+			continue
+		}
+		i++
+		switch v[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package vcard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Card
+	}{
+		{
+			name: "vcard 3.0 from Apple Contacts",
+			in: `BEGIN:VCARD
+VERSION:3.0
+PRODID:-//Apple Inc.//iPhone OS 17.0//EN
+N:Kowalska;Anna;;;
+FN:Anna Kowalska
+ORG:Example Sp. z o.o.;Engineering;
+item1.EMAIL;type=INTERNET;type=WORK:anna@work.example.com
+item1.X-ABLabel:work
+EMAIL;type=INTERNET;type=HOME;type=pref:anna@example.com
+TEL;type=CELL;type=VOICE;type=pref:+48 600 100 200
+END:VCARD`,
+			want: Card{
+				Version:       "3.0",
+				FormattedName: "Anna Kowalska",
+				Name:          Name{Family: "Kowalska", Given: "Anna"},
+				Emails:        []string{"anna@example.com", "anna@work.example.com"},
+				Phones:        []string{"+48 600 100 200"},
+				Organization:  "Example Sp. z o.o.",
+			},
+		},
+		{
+			name: "vcard 4.0 with folding and escapes",
+			in: "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Müller\\, Jonas\r\nN:Müller;Jonas;;Dr.;\r\n" +
+				"EMAIL;TYPE=work;PREF=2:jonas@work.exa\r\n mple.de\r\nEMAIL;PREF=1:jonas@example.de\r\n" +
+				"TEL;VALUE=uri;TYPE=\"voice,cell\":tel:+49-170-1234567\r\nEND:VCARD\r\n",
+			want: Card{
+				Version:       "4.0",
+				FormattedName: "Müller, Jonas",
+				Name:          Name{Family: "Müller", Given: "Jonas", Prefix: "Dr."},
+				Emails:        []string{"jonas@example.de", "jonas@work.example.de"},
+				Phones:        []string{"+49-170-1234567"},
+			},
+		},
+		{
+			name: "vcard 2.1 from a phone without FN",
+			in: `BEGIN:VCARD
+VERSION:2.1
+N;ENCODING=QUOTED-PRINTABLE;CHARSET=UTF-8:=C5=BBak;Pawe=C5=82;;;
+TEL;CELL;PREF:+48500600700
+TEL;HOME:221234567
+EMAIL;INTERNET:pawel@example.pl
+END:VCARD`,
+			want: Card{
+				Version:       "2.1",
+				FormattedName: "Paweł Żak",
+				Name:          Name{Family: "Żak", Given: "Paweł"},
+				Emails:        []string{"pawel@example.pl"},
+				Phones:        []string{"+48500600700", "221234567"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := Parse(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != 1 {
+				t.Fatalf("got %d cards", len(cards))
+			}
+			if !reflect.DeepEqual(*cards[0], tt.want) {
+				t.Errorf("got  %+v\nwant %+v", *cards[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"BEGIN:VCARD\nFN:Unclosed\n",
+		"FN:No card\nEND:VCARD\n",
+		"BEGIN:VCARD\nnot a property\nEND:VCARD\n",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseMultiple(t *testing.T) {
+	in := "BEGIN:VCARD\nVERSION:3.0\nFN:A\nEND:VCARD\nBEGIN:VCARD\nVERSION:3.0\nFN:B\nEND:VCARD\n"
+	cards, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 2 || cards[0].FormattedName != "A" || cards[1].FormattedName != "B" {
+		t.Errorf("cards = %+v", cards)
+	}
+}